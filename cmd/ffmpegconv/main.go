@@ -2,180 +2,113 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"os/signal"
-	"path/filepath"
-	"sync"
-	"time"
 
 	"github.com/vps2/futilities/internal/converter/ffmpeg"
 	"github.com/vps2/futilities/internal/fs"
+	"github.com/vps2/futilities/internal/runner"
 
 	flag "github.com/spf13/pflag"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
-	"gopkg.in/natefinch/lumberjack.v2"
-)
-
-var (
-	srcDir, dstDir                                     *string
-	inputFileOptions, outputFileOptions, outputFileExt *string
-	pollInterval                                       *time.Duration
 )
 
 func main() {
-	log := createLogger().Sugar()
-	defer log.Sync()
-	log.Info("The application is starting...")
-	defer log.Info("The application is stopped.")
-
-	srcDir = flag.StringP("src-dir", "s", "", "the folder where new files are tracked")
-	dstDir = flag.StringP("dst-dir", "d", "", "the folder where converted files from the source folder will be placed")
-	pollInterval = flag.DurationP("timeout", "t", 60*time.Second, "the timeout between polls of the source directory")
-	inputFileOptions = flag.StringP("ifile-opts", "i", "", "input file options for ffmpeg")
-	outputFileOptions = flag.StringP("ofile-opts", "o", "", "output file options for ffmpeg")
-	outputFileExt = flag.StringP("ofile-ext", "e", "", "output file extension")
-	help := flag.BoolP("help", "h", false, "show help")
-
-	flag.CommandLine.MarkHidden("help")
-	flag.CommandLine.SortFlags = false
-	flag.Parse()
-
-	if len(os.Args[1:]) == 0 || *help == true {
-		flag.Usage()
-		os.Exit(0)
-	}
+	runner.New(runner.Info{
+		Name:        "ffmpegconv",
+		Verb:        "converted",
+		DstDirUsage: "the folder where converted files from the source folder will be placed",
+		JobKeys:     []string{"ifile-opts", "ofile-opts", "ofile-ext"},
+		JobFlags:    []string{"ifile-opts", "ofile-opts", "ofile-ext"},
+	}, &command{}).Main()
+}
 
-	if err := checkDirFlag("src-dir"); err != nil {
-		log.Fatal(err)
-	}
-	if err := checkDirFlag("dst-dir"); err != nil {
-		log.Fatal(err)
-	}
-	if *srcDir == *dstDir {
-		log.Fatal("source and destination folders are the same")
-	}
+//command конвертирует найденные файлы с помощью ffmpeg.
+type command struct {
+	inputFileOptions  *string
+	outputFileOptions *string
+	outputFileExt     *string
+	onConflict        *string
 
-	dirReader := fs.NewDirReaderWithFilter(*srcDir, func(fileInfo os.FileInfo) bool { return fileInfo.Mode().IsRegular() })
-	watcher := fs.NewDirWatcher(dirReader, *pollInterval)
-	ffmpeg, err := ffmpeg.New(*srcDir, *dstDir, *inputFileOptions, *outputFileOptions, *outputFileExt)
-	if err != nil {
-		log.Fatal("ffmpeg converter was not found")
-	}
+	recursive      bool
+	conflictPolicy fs.ConflictPolicy
+}
 
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	go func() {
-		defer wg.Done()
-
-		watcher.Watch(ctx)
-	}()
-
-	events := watcher.Events()
-	errors := watcher.Errors()
-	go func() {
-		defer wg.Done()
-
-	loop:
-		for {
-			select {
-			case <-ctx.Done():
-				break loop
-			case files := <-events:
-				for _, file := range files {
-					select {
-					case <-ctx.Done():
-						break loop
-					default:
-					}
-
-					log.Infof("trying to convert a file '%s'", file.AbsolutePath())
-					if err := ffmpeg.Convert(file); err != nil {
-						log.Error(err)
-					} else {
-						log.Infof("the file '%s' was converted", file.AbsolutePath())
-						file.Delete()
-					}
-				}
-			case err := <-errors:
-				if err != nil {
-					log.Error(err)
-				}
-				break loop
-			}
-		}
-	}()
-
-	stopChan := make(chan os.Signal, 1)
-	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C)
-	// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught.
-	signal.Notify(stopChan, os.Interrupt)
-
-	log.Info("The application is started.")
-
-	// Ждём сигнала завершения от операционной системы или ошибки от watcher-ра
-	select {
-	case <-stopChan:
-	case <-errors:
-	}
+//jobSpec параметры ffmpeg задания.
+type jobSpec struct {
+	inputFileOptions  string
+	outputFileOptions string
+	outputFileExt     string
+}
 
-	cancel()
-	wg.Wait()
+func (c *command) DefineFlags(flags *flag.FlagSet) {
+	c.inputFileOptions = flags.StringP("ifile-opts", "i", "", "input file options for ffmpeg")
+	c.outputFileOptions = flags.StringP("ofile-opts", "o", "", "output file options for ffmpeg")
+	c.outputFileExt = flags.StringP("ofile-ext", "e", "", "output file extension")
+	c.onConflict = flags.String("on-conflict", fs.ConflictFail.String(), "what to do if the destination file already exists: fail, skip, overwrite, rename, rename-timestamp, keep-newer or keep-larger")
 }
 
-func checkDirFlag(name string) (err error) {
-	isFlagFound := false
-	flagValue := ""
+func (c *command) Configure(opts *runner.Options) error {
+	conflictPolicy, err := fs.ParseConflictPolicy(*c.onConflict)
+	if err != nil {
+		return err
+	}
+	c.conflictPolicy = conflictPolicy
+	c.recursive = opts.Recursive
 
-	flag.Visit(func(f *flag.Flag) {
-		if name == f.Name {
-			isFlagFound = true
-			flagValue = f.Value.String()
-		}
-	})
+	return nil
+}
 
-	if !isFlagFound || flagValue == "" {
-		err = fmt.Errorf("'%s' flag is not set", name)
+func (c *command) ParseJob(job runner.Job, fields map[string]string) (interface{}, error) {
+	spec := jobSpec{
+		inputFileOptions:  *c.inputFileOptions,
+		outputFileOptions: *c.outputFileOptions,
+		outputFileExt:     *c.outputFileExt,
+	}
+	if value, ok := fields["ifile-opts"]; ok {
+		spec.inputFileOptions = value
+	}
+	if value, ok := fields["ofile-opts"]; ok {
+		spec.outputFileOptions = value
+	}
+	if value, ok := fields["ofile-ext"]; ok {
+		spec.outputFileExt = value
+	}
 
-	} else {
-		if err = checkDir(flagValue); err != nil {
-			err = fmt.Errorf("the directory for the flag '%s' does not exist", name)
-		}
+	if job.DstDir == "" {
+		return nil, fmt.Errorf("the destination folder is not set")
+	}
+	if err := runner.CheckDir(job.DstDir); err != nil {
+		return nil, err
 	}
 
-	return err
+	return spec, nil
 }
 
-func checkDir(name string) error {
-	stat, err := os.Stat(name)
-	if err != nil {
-		return fmt.Errorf("%s is not exists", name)
+func (c *command) NewAction(log *zap.SugaredLogger, job runner.Job, startup bool) (runner.Action, error) {
+	spec := job.Spec.(jobSpec)
+	if err := runner.CheckDestination(job.SrcDir, job.DstDir, c.recursive); err != nil {
+		return nil, err
 	}
 
-	if !stat.IsDir() {
-		return fmt.Errorf("%s is not a directory", name)
+	converter, err := ffmpeg.New(job.SrcDir, job.DstDir, spec.inputFileOptions, spec.outputFileOptions, spec.outputFileExt, c.conflictPolicy)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return runner.ActionFunc(func(ctx context.Context, file *fs.File) {
+		convert(ctx, log, converter, file)
+	}), nil
 }
 
-func createLogger() *zap.Logger {
-	writer := zapcore.AddSync(&lumberjack.Logger{
-		Filename:   filepath.Join(filepath.Dir(os.Args[0]), "ffmpegconv.log"),
-		MaxSize:    10, // megabytes
-		MaxBackups: 3,
-	})
-	encoder := zap.NewProductionEncoderConfig()
-	encoder.TimeKey = "time"
-	encoder.EncodeTime = zapcore.RFC3339TimeEncoder
-	core := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoder),
-		writer,
-		zap.InfoLevel,
-	)
-
-	return zap.New(core)
+func convert(ctx context.Context, log *zap.SugaredLogger, converter *ffmpeg.FFMPEG, file *fs.File) {
+	log.Infof("trying to convert a file '%s'", file.AbsolutePath())
+	if err := converter.Convert(ctx, file); errors.Is(err, fs.ErrSkipped) {
+		log.Debug(err)
+	} else if err != nil {
+		log.Error(err)
+	} else {
+		log.Infof("the file '%s' was converted", file.AbsolutePath())
+		file.Delete()
+	}
 }
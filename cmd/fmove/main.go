@@ -2,170 +2,381 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/signal"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/vps2/futilities/internal/fs"
+	"github.com/vps2/futilities/internal/rules"
+	"github.com/vps2/futilities/internal/runner"
 
 	flag "github.com/spf13/pflag"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
-	"gopkg.in/natefinch/lumberjack.v2"
-)
-
-var (
-	srcDir, dstDir *string
-	pollInterval   *time.Duration
 )
 
 func main() {
-	log := createLogger().Sugar()
-	defer log.Sync()
-	log.Info("The application is starting...")
-	defer log.Info("The application is stopped.")
-
-	srcDir = flag.StringP("src-dir", "s", "", "the folder where new files are tracked")
-	dstDir = flag.StringP("dst-dir", "d", "", "the folder where new files will be moved from the source folder")
-	pollInterval = flag.DurationP("timeout", "t", 60*time.Second, "the timeout between polls of the source directory")
-	help := flag.BoolP("help", "h", false, "show help")
-
-	flag.CommandLine.MarkHidden("help")
-	flag.CommandLine.SortFlags = false
-	flag.Parse()
-
-	if len(os.Args[1:]) == 0 || *help == true {
-		flag.Usage()
-		os.Exit(0)
-	}
-
-	if err := checkDirFlag("src-dir"); err != nil {
-		log.Fatal(err)
-	}
-	if err := checkDirFlag("dst-dir"); err != nil {
-		log.Fatal(err)
-	}
-	if *srcDir == *dstDir {
-		log.Fatal("source and destination folders are the same")
-	}
-
-	dirReader := fs.NewDirReaderWithFilter(*srcDir, func(fileInfo os.FileInfo) bool { return fileInfo.Mode().IsRegular() })
-	watcher := fs.NewDirWatcher(dirReader, *pollInterval)
-
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	go func() {
-		defer wg.Done()
-
-		watcher.Watch(ctx)
-	}()
-
-	events := watcher.Events()
-	errors := watcher.Errors()
-	go func() {
-		defer wg.Done()
-
-	loop:
-		for {
-			select {
-			case <-ctx.Done():
-				break loop
-			case files := <-events:
-				for _, file := range files {
-					select {
-					case <-ctx.Done():
-						break loop
-					default:
-					}
-
-					log.Infof("trying to move a file '%s' to folder '%s'", file.AbsolutePath(), *dstDir)
-					if err := file.MoveTo(*dstDir); err != nil {
-						log.Error(err)
-					} else {
-						log.Infof("the file '%s' was moved", file.AbsolutePath())
-					}
-				}
-			case err := <-errors:
-				if err != nil {
-					log.Error(err)
-				}
-				break loop
-			}
+	runner.New(runner.Info{
+		Name:        "fmove",
+		Verb:        "moved",
+		DstDirUsage: "the folder where new files will be moved from the source folder",
+		JobKeys:     []string{"template", "rules"},
+		JobFlags:    []string{"dst-template", "rules"},
+	}, &command{}).Main()
+}
+
+//command перемещает (копирует, удаляет) найденные файлы по правилам.
+type command struct {
+	verify        *string
+	onConflict    *string
+	bwLimit       *string
+	bwSchedule    *[]string
+	progressEvery *time.Duration
+	preserve      *string
+	dstTemplate   *string
+	rulesFile     *string
+
+	settings *settings
+	copies   *copies
+}
+
+//settings общие для всех заданий настройки.
+type settings struct {
+	recursive      bool
+	hashAlgorithm  fs.HashAlgorithm
+	conflictPolicy fs.ConflictPolicy
+	attributes     fs.Attributes
+	progressEvery  time.Duration
+	//limits значения флагов, от которых зависит ограничитель скорости
+	limits  string
+	limiter *fs.RateLimiter
+}
+
+//jobSpec настройки задания, зависящие от утилиты.
+type jobSpec struct {
+	dstTemplate string
+	rulesFile   string
+	//rulesVersion позволяет заметить изменение файла правил
+	rulesVersion string
+}
+
+func (c *command) DefineFlags(flags *flag.FlagSet) {
+	c.verify = flags.String("verify", "", "verify copied files by checksum before deleting the source: sha256, crc32 or xxhash")
+	c.onConflict = flags.String("on-conflict", fs.ConflictFail.String(), "what to do if the destination file already exists: fail, skip, overwrite, rename, rename-timestamp, keep-newer or keep-larger")
+	c.bwLimit = flags.String("bwlimit", "", "the total copy speed limit in bytes per second (e.g. 512K, 10M)")
+	c.bwSchedule = flags.StringArray("bwlimit-schedule", nil, "the copy speed limit for the time of day, e.g. 08:00-18:00=10M or 22:00-06:00=unlimited; can be repeated")
+	c.progressEvery = flags.Duration("progress-interval", 0, "how often to log the progress of copying a file (0 - do not log)")
+	c.preserve = flags.String("preserve", fs.AttrTimes.String(), "comma-separated file attributes preserved on copy: times, mode, owner (as root only), xattr or all")
+	c.dstTemplate = flags.String("dst-template", "", "the template of the destination path (absolute or relative to the destination folder), e.g. {mtime:2006}/{mtime:01}/{name}; placeholders: {name}, {base}, {ext}, {dir}, {mtime:layout}, {atime:layout}, {now:layout}")
+	c.rulesFile = flags.String("rules", "", "the YAML file with rules that choose an action (move, copy or delete) and a destination for each file; replaces --dst-dir and --dst-template")
+}
+
+func (c *command) Configure(opts *runner.Options) error {
+	var err error
+	s := &settings{recursive: opts.Recursive, progressEvery: *c.progressEvery}
+
+	if s.hashAlgorithm, err = fs.ParseHashAlgorithm(*c.verify); err != nil {
+		return err
+	}
+	if s.conflictPolicy, err = fs.ParseConflictPolicy(*c.onConflict); err != nil {
+		return err
+	}
+	if s.attributes, err = fs.ParseAttributes(*c.preserve); err != nil {
+		return err
+	}
+	//при неизменных ограничениях скорости сохраняется и общий ограничитель скорости
+	s.limits = fmt.Sprintf("%s/%s", *c.bwLimit, strings.Join(*c.bwSchedule, ","))
+	if c.settings != nil && c.settings.limits == s.limits {
+		s.limiter = c.settings.limiter
+	} else if s.limiter, err = c.createRateLimiter(); err != nil {
+		return err
+	}
+
+	c.settings = s
+	if c.copies == nil {
+		c.copies = newCopies()
+	}
+
+	return nil
+}
+
+func (c *command) ParseJob(job runner.Job, fields map[string]string) (interface{}, error) {
+	spec := jobSpec{
+		dstTemplate: *c.dstTemplate,
+		rulesFile:   *c.rulesFile,
+	}
+	if value, ok := fields["template"]; ok {
+		spec.dstTemplate = value
+	}
+	if value, ok := fields["rules"]; ok {
+		spec.rulesFile = value
+	}
+	if spec.rulesFile != "" {
+		spec.rulesVersion = runner.FileVersion(spec.rulesFile)
+	}
+
+	return spec, nil
+}
+
+//NewAction проверяет правила задания и создаёт папки назначения. При запуске утилиты из папок назначения
+//удаляются временные файлы, оставшиеся от предыдущего запуска (при перезагрузке настроек их может
+//дописывать предыдущее задание).
+func (c *command) NewAction(log *zap.SugaredLogger, job runner.Job, startup bool) (runner.Action, error) {
+	settings := c.settings
+	ruleSet, err := createRuleSet(job)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range ruleSet.Rules {
+		if rule.Action == rules.ActionDelete {
+			continue
+		}
+		if err := runner.CheckDestination(job.SrcDir, rule.Destination, settings.recursive); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(rule.Destination, 0755); err != nil {
+			return nil, err
+		}
+		if !startup {
+			continue
+		}
+
+		//при использовании шаблона файлы могут оказаться в любом подкаталоге папки назначения
+		removed, err := fs.RemovePartialFiles(rule.Destination, settings.recursive || rule.Template != nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, pathName := range removed {
+			log.Infof("the incomplete file '%s' left from the previous run was removed", pathName)
 		}
-	}()
+	}
 
-	stopChan := make(chan os.Signal, 1)
-	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C)
-	// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught.
-	signal.Notify(stopChan, os.Interrupt)
+	opts := createCopyOptions(log, settings)
 
-	log.Info("The application is started.")
+	return runner.ActionFunc(func(ctx context.Context, file *fs.File) {
+		processFile(ctx, log, file, ruleSet, opts, c.copies)
+	}), nil
+}
 
-	// Ждём сигнала завершения от операционной системы или ошибки от watcher-ра
-	select {
-	case <-stopChan:
-	case <-errors:
+func createCopyOptions(log *zap.SugaredLogger, settings *settings) []fs.CopyOption {
+	opts := []fs.CopyOption{
+		fs.WithVerify(settings.hashAlgorithm),
+		fs.WithConflictPolicy(settings.conflictPolicy),
+		fs.WithRateLimit(settings.limiter),
+		fs.WithPreserve(settings.attributes),
+	}
+	if settings.progressEvery > 0 {
+		opts = append(opts, fs.WithProgress(settings.progressEvery, func(progress fs.Progress) {
+			log.Infof("copying a file '%s': %.0f%% (%s of %s), %s/s, ETA %s",
+				progress.File.AbsolutePath(),
+				percent(progress.Copied, progress.Total),
+				fs.FormatSize(progress.Copied),
+				fs.FormatSize(progress.Total),
+				fs.FormatSize(int64(progress.Throughput)),
+				progress.ETA.Round(time.Second))
+		}))
 	}
 
-	cancel()
-	wg.Wait()
+	return opts
 }
 
-func checkDirFlag(name string) (err error) {
-	isFlagFound := false
-	flagValue := ""
+//processFile применяет к файлу подходящие правила. Если действие правила не удалось выполнить,
+//то следующие правила не применяются.
+func processFile(ctx context.Context, log *zap.SugaredLogger, file *fs.File, ruleSet *rules.Set, opts []fs.CopyOption, copies *copies) {
+	stat, err := os.Stat(file.AbsolutePath())
+	if err != nil {
+		log.Error(err)
+		return
+	}
 
-	flag.Visit(func(f *flag.Flag) {
-		if name == f.Name {
-			isFlagFound = true
-			flagValue = f.Value.String()
+	for _, rule := range ruleSet.Match(stat) {
+		if ok := applyRule(ctx, log, file, stat, rule, opts, copies); !ok {
+			return
 		}
-	})
+	}
+}
 
-	if !isFlagFound || flagValue == "" {
-		err = fmt.Errorf("'%s' flag is not set", name)
+func applyRule(ctx context.Context, log *zap.SugaredLogger, file *fs.File, stat os.FileInfo, rule *rules.Rule, opts []fs.CopyOption, copies *copies) bool {
+	if rule.Action == rules.ActionDelete {
+		log.Infof("trying to delete a file '%s' (rule '%s')", file.AbsolutePath(), rule)
+		if err := file.Delete(); err != nil {
+			log.Error(err)
+			return false
+		}
+		log.Infof("the file '%s' was deleted", file.AbsolutePath())
+		return true
+	}
 
+	pathName, err := rule.Target(file)
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	targetDir := filepath.Dir(pathName)
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		log.Error(err)
+		return false
+	}
+	//opts используется одновременно несколькими обработчиками, поэтому добавление не должно менять его массив
+	opts = append(opts[:len(opts):len(opts)], fs.WithTargetName(filepath.Base(pathName)))
+
+	if rule.Action == rules.ActionCopy {
+		if copies.contains(file, stat, rule) {
+			log.Debugf("the file '%s' was already copied (rule '%s')", file.AbsolutePath(), rule)
+			return true
+		}
+
+		log.Infof("trying to copy a file '%s' to folder '%s' (rule '%s')", file.AbsolutePath(), targetDir, rule)
+		dstFile, err := file.CopyToContext(ctx, targetDir, opts...)
+		ok := logResult(log, err, "the file '%s' was copied to '%s'", file.AbsolutePath(), dstFile)
+		if ok {
+			copies.add(file, stat, rule)
+		}
+		return ok
+	}
+
+	log.Infof("trying to move a file '%s' to folder '%s' (rule '%s')", file.AbsolutePath(), targetDir, rule)
+	strategy, err := file.MoveToContext(ctx, targetDir, opts...)
+	return logResult(log, err, "the file '%s' was moved (%s)", file.AbsolutePath(), strategy)
+}
+
+//logResult записывает в журнал результат действия над файлом. Пропуск файла из-за конфликта имён ошибкой не считается.
+func logResult(log *zap.SugaredLogger, err error, template string, args ...interface{}) bool {
+	if errors.Is(err, fs.ErrSkipped) {
+		log.Debug(err)
+		return true
+	} else if errors.Is(err, context.Canceled) {
+		log.Warn(err)
+	} else if err != nil {
+		log.Error(err)
 	} else {
-		if err = checkDir(flagValue); err != nil {
-			err = fmt.Errorf("the directory for the flag '%s' does not exist", name)
+		log.Infof(template, args...)
+		return true
+	}
+
+	return false
+}
+
+//copies запоминает файлы, скопированные правилами. После копирования файл остаётся в отслеживаемой папке
+//и находится при каждом опросе, поэтому повторно он копируется, только если изменились его размер
+//или время модификации.
+type copies struct {
+	mu    sync.Mutex
+	files map[copyKey]copyVersion
+	//sweepSize количество запомненных копий, при котором забываются копии удалённых файлов
+	sweepSize int
+}
+
+type copyKey struct {
+	pathName string
+	rule     string
+}
+
+type copyVersion struct {
+	size    int64
+	modTime time.Time
+}
+
+//minSweepSize минимальное количество запомненных копий, при котором забываются копии удалённых файлов.
+const minSweepSize = 1024
+
+func newCopies() *copies {
+	return &copies{files: make(map[copyKey]copyVersion), sweepSize: minSweepSize}
+}
+
+func newCopyKey(file *fs.File, rule *rules.Rule) copyKey {
+	return copyKey{pathName: file.AbsolutePath(), rule: fmt.Sprintf("%s:%s", rule, rule.Destination)}
+}
+
+//contains проверяет, что файл в текущем состоянии уже скопирован правилом.
+func (c *copies) contains(file *fs.File, stat os.FileInfo, rule *rules.Rule) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	version, ok := c.files[newCopyKey(file, rule)]
+	return ok && version == copyVersion{size: stat.Size(), modTime: stat.ModTime()}
+}
+
+//add запоминает, что файл в текущем состоянии скопирован правилом.
+func (c *copies) add(file *fs.File, stat os.FileInfo, rule *rules.Rule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.files[newCopyKey(file, rule)] = copyVersion{size: stat.Size(), modTime: stat.ModTime()}
+	if len(c.files) < c.sweepSize {
+		return
+	}
+
+	for key := range c.files {
+		if _, err := os.Stat(key.pathName); os.IsNotExist(err) {
+			delete(c.files, key)
 		}
 	}
+	if c.sweepSize = 2 * len(c.files); c.sweepSize < minSweepSize {
+		c.sweepSize = minSweepSize
+	}
+}
+
+func percent(value, total int64) float64 {
+	if total == 0 {
+		return 100
+	}
 
-	return err
+	return float64(value) / float64(total) * 100
 }
 
-func checkDir(name string) error {
-	stat, err := os.Stat(name)
-	if err != nil {
-		return fmt.Errorf("%s is not exists", name)
+func (c *command) createRateLimiter() (*fs.RateLimiter, error) {
+	if *c.bwLimit == "" && len(*c.bwSchedule) == 0 {
+		return nil, nil
 	}
 
-	if !stat.IsDir() {
-		return fmt.Errorf("%s is not a directory", name)
+	var schedule fs.RateSchedule
+	if *c.bwLimit != "" {
+		rate, err := fs.ParseRate(*c.bwLimit)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Default = rate
+	}
+	for _, s := range *c.bwSchedule {
+		rule, err := fs.ParseRateRule(s)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Rules = append(schedule.Rules, rule)
 	}
 
-	return nil
+	return fs.NewScheduledRateLimiter(schedule), nil
 }
 
-func createLogger() *zap.Logger {
-	writer := zapcore.AddSync(&lumberjack.Logger{
-		Filename:   filepath.Join(filepath.Dir(os.Args[0]), "fmove.log"),
-		MaxSize:    10, // megabytes
-		MaxBackups: 3,
-	})
-	encoder := zap.NewProductionEncoderConfig()
-	encoder.TimeKey = "time"
-	encoder.EncodeTime = zapcore.RFC3339TimeEncoder
-	core := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoder),
-		writer,
-		zap.InfoLevel,
-	)
-
-	return zap.New(core)
+//createRuleSet возвращает правила из файла, указанного в задании (флагом --rules), или единственное правило,
+//перемещающее все файлы в папку назначения задания (указанную флагом --dst-dir).
+func createRuleSet(job runner.Job) (*rules.Set, error) {
+	spec := job.Spec.(jobSpec)
+	if spec.rulesFile != "" {
+		if job.DstDir != "" || spec.dstTemplate != "" {
+			return nil, fmt.Errorf("the rules file can not be used together with the destination folder and template")
+		}
+		return rules.Load(spec.rulesFile)
+	}
+
+	if job.DstDir == "" {
+		return nil, fmt.Errorf("the destination folder is not set")
+	}
+	if err := runner.CheckDir(job.DstDir); err != nil {
+		return nil, err
+	}
+	rule := &rules.Rule{Action: rules.ActionMove, Destination: job.DstDir}
+	if spec.dstTemplate != "" {
+		template, err := fs.ParsePathTemplate(spec.dstTemplate)
+		if err != nil {
+			return nil, err
+		}
+		rule.Template = template
+	}
+
+	return &rules.Set{Rules: []*rules.Rule{rule}}, nil
 }
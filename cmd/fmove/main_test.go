@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vps2/futilities/internal/fs"
+	"github.com/vps2/futilities/internal/rules"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func Test_processFileCopy(t *testing.T) {
+	dirName, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirName)
+
+	srcDirName := filepath.Join(dirName, "src")
+	dstDirName := filepath.Join(dirName, "dst")
+	for _, name := range []string{srcDirName, dstDirName} {
+		if err := os.Mkdir(name, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srcPathName := filepath.Join(srcDirName, "file.txt")
+	if err := ioutil.WriteFile(srcPathName, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ruleSet := &rules.Set{Rules: []*rules.Rule{{Action: rules.ActionCopy, Destination: dstDirName}}}
+	copies := newCopies()
+	core, errorLogs := observer.New(zapcore.ErrorLevel)
+	log := zap.New(core).Sugar()
+	dstFiles := func() (names []string) {
+		files, err := fs.NewDirReader(dstDirName).Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			names = append(names, file.Name())
+		}
+		return
+	}
+
+	//файл остаётся в исходной папке и находится при каждом опросе
+	for _, policy := range []fs.ConflictPolicy{fs.ConflictFail, fs.ConflictRename} {
+		opts := []fs.CopyOption{fs.WithConflictPolicy(policy)}
+		for poll := 0; poll < 2; poll++ {
+			processFile(context.Background(), log, &fs.File{PathName: srcPathName}, ruleSet, opts, copies)
+		}
+		assert.Equal(t, []string{"file.txt"}, dstFiles())
+		assert.Zero(t, errorLogs.Len())
+	}
+
+	//изменённый файл копируется заново
+	mtime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(srcPathName, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	opts := []fs.CopyOption{fs.WithConflictPolicy(fs.ConflictRename)}
+	processFile(context.Background(), log, &fs.File{PathName: srcPathName}, ruleSet, opts, copies)
+	assert.ElementsMatch(t, []string{"file.txt", "file (1).txt"}, dstFiles())
+}
@@ -3,9 +3,12 @@ module github.com/vps2/futilities
 go 1.15
 
 require (
+	github.com/cespare/xxhash/v2 v2.1.2
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.6.1
 	go.uber.org/zap v1.16.0
+	golang.org/x/sys v0.0.0-20210423082822-04245dca01da
 	gopkg.in/djherbis/times.v1 v1.2.0
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
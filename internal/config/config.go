@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	flag "github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+//jobsKey ключ файла настроек, под которым перечисляются задания (совпадает с флагом --watch).
+const jobsKey = "watch"
+
+//Config содержимое файла настроек в формате YAML. Ключи верхнего уровня совпадают с длинными именами флагов
+//командной строки, а ключ watch содержит список заданий (каждое задание - набор пар ключ-значение):
+//
+//	timeout: 30s
+//	recursive: true
+//	include: ["*.mp4", "*.avi"]
+//	watch:
+//	  - name: video
+//	    src: /in/video
+//	    dst: /out/video
+type Config struct {
+	//PathName путь к файлу настроек
+	PathName string
+	//Options значения флагов
+	Options map[string]interface{}
+	//Jobs настройки заданий
+	Jobs []map[string]string
+}
+
+//Load читает файл настроек.
+func Load(pathName string) (*Config, error) {
+	data, err := ioutil.ReadFile(pathName)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pathName, err)
+	}
+	c.PathName = pathName
+
+	return c, nil
+}
+
+//Parse разбирает содержимое файла настроек.
+func Parse(data []byte) (*Config, error) {
+	var options map[string]interface{}
+	if err := yaml.Unmarshal(data, &options); err != nil {
+		return nil, err
+	}
+
+	var jobs struct {
+		Jobs []map[string]string `yaml:"watch"`
+	}
+	if _, ok := options[jobsKey]; ok {
+		if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&jobs); err != nil {
+			return nil, fmt.Errorf("invalid jobs: %w", err)
+		}
+		delete(options, jobsKey)
+	}
+
+	return &Config{Options: options, Jobs: jobs.Jobs}, nil
+}
+
+//Apply задаёт значения флагов из файла настроек. Флаги, заданные в командной строке, не меняются.
+//Значения проверяются так же, как значения флагов командной строки.
+func (c *Config) Apply(flags *flag.FlagSet) error {
+	//сортировка нужна, чтобы при нескольких ошибках сообщалось об одной и той же
+	names := make([]string, 0, len(c.Options))
+	for name := range c.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := flags.Lookup(name)
+		if f == nil || name == "help" || name == "config" {
+			return fmt.Errorf("unknown option '%s'", name)
+		}
+		if f.Changed {
+			continue
+		}
+
+		values, err := flagValues(f, c.Options[name])
+		if err != nil {
+			return fmt.Errorf("option '%s': %w", name, err)
+		}
+		for _, value := range values {
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("option '%s': %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+//flagValues возвращает значения, которые нужно последовательно передать флагу. Список допустим
+//только для флагов, которые можно повторять.
+func flagValues(f *flag.Flag, value interface{}) ([]string, error) {
+	list, isList := value.([]interface{})
+	if !isList {
+		if _, isMap := value.(map[string]interface{}); isMap || value == nil {
+			return nil, fmt.Errorf("a value is expected")
+		}
+		return []string{fmt.Sprint(value)}, nil
+	}
+
+	switch f.Value.Type() {
+	case "stringArray", "stringSlice":
+	default:
+		return nil, fmt.Errorf("a single value is expected")
+	}
+
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		values = append(values, fmt.Sprint(item))
+	}
+
+	return values, nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFlagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.DurationP("timeout", "t", time.Minute, "")
+	flags.Bool("recursive", false, "")
+	flags.Int("jobs", 1, "")
+	flags.String("ofile-opts", "", "")
+	flags.StringArray("include", nil, "")
+
+	return flags
+}
+
+func TestConfig_Apply(t *testing.T) {
+	c, err := Parse([]byte(`
+timeout: 30s
+recursive: true
+jobs: 4
+ofile-opts: "-c:v libx264 -crf 24"
+include: ["*.mp4", "*.avi"]
+`))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Empty(t, c.Jobs)
+
+	flags := newFlagSet()
+	assert.Nil(t, flags.Parse([]string{"-t", "5s"}))
+	assert.Nil(t, c.Apply(flags))
+
+	//значение из командной строки не меняется
+	timeout, _ := flags.GetDuration("timeout")
+	assert.Equal(t, 5*time.Second, timeout)
+	recursive, _ := flags.GetBool("recursive")
+	assert.True(t, recursive)
+	jobs, _ := flags.GetInt("jobs")
+	assert.Equal(t, 4, jobs)
+	opts, _ := flags.GetString("ofile-opts")
+	assert.Equal(t, "-c:v libx264 -crf 24", opts)
+	include, _ := flags.GetStringArray("include")
+	assert.Equal(t, []string{"*.mp4", "*.avi"}, include)
+}
+
+func TestConfig_ApplyInvalid(t *testing.T) {
+	invalid := []string{
+		`timeout: 30`,
+		`recursive: maybe`,
+		`jobs: [1, 2]`,
+		`unknown: 1`,
+		`help: true`,
+		`include: {a: b}`,
+	}
+	for _, data := range invalid {
+		c, err := Parse([]byte(data))
+		if !assert.Nil(t, err, data) {
+			continue
+		}
+		assert.NotNil(t, c.Apply(newFlagSet()), data)
+	}
+
+	_, err := Parse([]byte("timeout: [30s"))
+	assert.NotNil(t, err)
+	_, err = Parse([]byte("jobs: 4\njobs: 5"))
+	assert.NotNil(t, err)
+}
+
+func TestParseJobs(t *testing.T) {
+	c, err := Parse([]byte(`
+timeout: 30s
+watch:
+  - name: video
+    src: /in/video
+    dst: /out/video
+    timeout: 10s
+  - src: /in/audio
+    dst: /out/audio
+`))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []map[string]string{
+		{"name": "video", "src": "/in/video", "dst": "/out/video", "timeout": "10s"},
+		{"src": "/in/audio", "dst": "/out/audio"},
+	}, c.Jobs)
+	assert.Equal(t, map[string]interface{}{"timeout": "30s"}, c.Options)
+
+	_, err = Parse([]byte("watch: [[1, 2]]"))
+	assert.NotNil(t, err)
+}
@@ -2,7 +2,9 @@ package ffmpeg
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,19 +15,21 @@ import (
 	"github.com/vps2/futilities/internal/fs"
 )
 
-var ffmpegPathName string
-
 //FFMPEG оболочка для запуска внешнего конвертера ffmpeg
 type FFMPEG struct {
+	pathName          string
 	srcDir            string
 	dstDir            string
 	inputFileOptions  []string
 	outputFileOptions []string
 	outputFileExt     string
+	conflictPolicy    fs.ConflictPolicy
 }
 
-//Convert запускает ffmpeg для конвертации файла
-func (f *FFMPEG) Convert(file *fs.File) error {
+//Convert запускает ffmpeg для конвертации файла. При отмене ctx процесс ffmpeg завершается,
+//а недописанный результат конвертации удаляется. Существующий файл, который нужно перезаписать,
+//заменяется результатом конвертации только после её успешного завершения.
+func (f *FFMPEG) Convert(ctx context.Context, file *fs.File) error {
 	dstFileExt := f.outputFileExt
 	if dstFileExt == "" {
 		dstFileExt = filepath.Ext(file.Name())
@@ -33,11 +37,28 @@ func (f *FFMPEG) Convert(file *fs.File) error {
 
 	inputFileName := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
 
-	dstFileName := filepath.Join(f.dstDir, inputFileName)
+	//для файлов из подкаталогов воссоздаём их дерево в каталоге назначения
+	dstDir := filepath.Join(f.dstDir, file.RelativeDir())
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return err
+	}
+
+	dstFileName := filepath.Join(dstDir, inputFileName)
 	if dstFileExt != "" {
 		dstFileName = dstFileName + dstFileExt
 	}
 
+	dstFileName, overwrite, err := fs.ResolveConflict(file, dstFileName, f.conflictPolicy)
+	if err != nil {
+		return err
+	}
+
+	//при перезаписи ffmpeg пишет во временный файл, чтобы при ошибке существующий файл остался на месте
+	outputFileName := dstFileName
+	if overwrite {
+		outputFileName = tempPathName(dstFileName)
+	}
+
 	var args []string
 	if len(f.inputFileOptions) != 0 {
 		args = append(args, f.inputFileOptions...)
@@ -46,23 +67,40 @@ func (f *FFMPEG) Convert(file *fs.File) error {
 	if len(f.outputFileOptions) != 0 {
 		args = append(args, f.outputFileOptions...)
 	}
-	args = append(args, dstFileName)
+	args = append(args, outputFileName)
 
-	err := run(ffmpegPathName, args)
+	err = run(ctx, f.pathName, args)
 	if err != nil {
+		//файл, который уже существовал до запуска ffmpeg, удалять нельзя
 		if match, _ := regexp.MatchString(`File '.*?' already exists.`, err.Error()); !match {
-			dstFile := fs.File{PathName: dstFileName}
-			dstFile.Delete()
+			os.Remove(outputFileName)
 		}
+		return err
 	}
 
-	return err
+	if overwrite {
+		if err := os.Rename(outputFileName, dstFileName); err != nil {
+			os.Remove(outputFileName)
+			return err
+		}
+	}
+
+	return nil
+}
+
+//tempPathName возвращает путь к временному файлу результата конвертации в каталоге файла pathName.
+//Расширение сохраняется, так как по нему ffmpeg определяет формат результата.
+func tempPathName(pathName string) string {
+	ext := filepath.Ext(pathName)
+	base := strings.TrimSuffix(filepath.Base(pathName), ext)
+
+	return filepath.Join(filepath.Dir(pathName), fmt.Sprintf(".%s.%d%s%s", base, rand.Uint32(), fs.PartialSuffix, ext))
 }
 
-func run(command string, args []string) error {
+func run(ctx context.Context, command string, args []string) error {
 	var buf bytes.Buffer
 
-	cmd := exec.Command(command, args...)
+	cmd := exec.CommandContext(ctx, command, args...)
 	cmd.Stderr = &buf
 	cmd.Stdin = os.Stdin
 
@@ -73,17 +111,20 @@ func run(command string, args []string) error {
 	return err
 }
 
-//New создает новый экземпляр конвертера.
-func New(srcDir, dstDir, inputFileOptions, outputFileOptions, outputFileExt string) (*FFMPEG, error) {
-	var err error
-	if ffmpegPathName, err = findFFMpeg(); err != nil {
+//New создает новый экземпляр конвертера. Аргумент conflictPolicy определяет, что делать, если
+//в каталоге назначения уже есть файл с именем результата конвертации.
+func New(srcDir, dstDir, inputFileOptions, outputFileOptions, outputFileExt string, conflictPolicy fs.ConflictPolicy) (*FFMPEG, error) {
+	pathName, err := findFFMpeg()
+	if err != nil {
 		return nil, fmt.Errorf("ffmpeg converter was not found: %w", err)
 	}
 
 	ffmpeg := FFMPEG{
-		srcDir:        srcDir,
-		dstDir:        dstDir,
-		outputFileExt: outputFileExt,
+		pathName:       pathName,
+		srcDir:         srcDir,
+		dstDir:         dstDir,
+		outputFileExt:  outputFileExt,
+		conflictPolicy: conflictPolicy,
 	}
 
 	if inputFileOptions != "" {
@@ -0,0 +1,77 @@
+package ffmpeg
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/vps2/futilities/internal/fs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//fakeFFMPEG создаёт сценарий, который записывает "new" в файл результата (последний аргумент)
+//и завершается с кодом exitCode.
+func fakeFFMPEG(t *testing.T, dirName, exitCode string) string {
+	pathName := filepath.Join(dirName, "ffmpeg-"+exitCode)
+	script := "#!/bin/sh\nfor last; do :; done\necho new > \"$last\"\nexit " + exitCode + "\n"
+	if err := ioutil.WriteFile(pathName, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return pathName
+}
+
+func TestFFMPEG_ConvertOverwrite(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+
+	dirName, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirName)
+
+	srcPathName := filepath.Join(dirName, "video.avi")
+	dstDirName := filepath.Join(dirName, "dst")
+	dstPathName := filepath.Join(dstDirName, "video.mkv")
+	if err := os.Mkdir(dstDirName, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, pathName := range []string{srcPathName, dstPathName} {
+		if err := ioutil.WriteFile(pathName, []byte("old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dstFiles := func() (names []string) {
+		files, err := fs.NewDirReader(dstDirName).Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			names = append(names, file.Name())
+		}
+		return
+	}
+
+	f := &FFMPEG{dstDir: dstDirName, outputFileExt: ".mkv", conflictPolicy: fs.ConflictOverwrite}
+
+	//при ошибке ffmpeg существующий файл остаётся на месте, а недописанный результат удаляется
+	f.pathName = fakeFFMPEG(t, dirName, "1")
+	assert.NotNil(t, f.Convert(context.Background(), &fs.File{PathName: srcPathName}))
+	data, err := ioutil.ReadFile(dstPathName)
+	assert.Nil(t, err)
+	assert.Equal(t, "old\n", string(data))
+	assert.Equal(t, []string{"video.mkv"}, dstFiles())
+
+	f.pathName = fakeFFMPEG(t, dirName, "0")
+	assert.Nil(t, f.Convert(context.Background(), &fs.File{PathName: srcPathName}))
+	data, err = ioutil.ReadFile(dstPathName)
+	assert.Nil(t, err)
+	assert.Equal(t, "new\n", string(data))
+	assert.Equal(t, []string{"video.mkv"}, dstFiles())
+}
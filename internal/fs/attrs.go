@@ -0,0 +1,107 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+//Attributes набор атрибутов файла, которые сохраняются при копировании.
+type Attributes int
+
+//Атрибуты файла
+const (
+	//AttrTimes время последнего доступа и модификации (с точностью до наносекунд, если её поддерживает файловая система)
+	AttrTimes Attributes = 1 << iota
+	//AttrMode права доступа, в том числе setuid, setgid и sticky-биты
+	AttrMode
+	//AttrOwner владелец и группа (только при запуске от имени root)
+	AttrOwner
+	//AttrXattrs расширенные атрибуты (только в Linux)
+	AttrXattrs
+
+	//AttrAll все атрибуты
+	AttrAll = AttrTimes | AttrMode | AttrOwner | AttrXattrs
+)
+
+var attributeNames = []struct {
+	attr Attributes
+	name string
+}{
+	{AttrTimes, "times"},
+	{AttrMode, "mode"},
+	{AttrOwner, "owner"},
+	{AttrXattrs, "xattr"},
+}
+
+func (a Attributes) String() string {
+	var names []string
+	for _, attribute := range attributeNames {
+		if a&attribute.attr != 0 {
+			names = append(names, attribute.name)
+		}
+	}
+
+	return strings.Join(names, ",")
+}
+
+//ParseAttributes разбирает список атрибутов, перечисленных через запятую: times, mode, owner, xattr или all.
+func ParseAttributes(s string) (attrs Attributes, err error) {
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == "all" {
+			attrs |= AttrAll
+			continue
+		}
+
+		found := false
+		for _, attribute := range attributeNames {
+			if attribute.name == name {
+				attrs |= attribute.attr
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown file attribute '%s'", name)
+		}
+	}
+
+	return
+}
+
+//preserveAttributes переносит выбранные атрибуты исходного файла на его копию.
+func preserveAttributes(srcPathName, dstPathName string, attrs Attributes) error {
+	srcStat, err := os.Stat(srcPathName)
+	if err != nil {
+		return err
+	}
+
+	if attrs&AttrXattrs != 0 {
+		if err := copyXattrs(srcPathName, dstPathName); err != nil {
+			return fmt.Errorf("can not copy extended attributes of the file '%s': %w", srcPathName, err)
+		}
+	}
+	//владелец меняется до прав доступа, так как смена владельца сбрасывает setuid и setgid биты
+	if attrs&AttrOwner != 0 {
+		if err := copyOwner(srcStat, dstPathName); err != nil {
+			return fmt.Errorf("can not change the owner of the file '%s': %w", dstPathName, err)
+		}
+	}
+	if attrs&AttrMode != 0 {
+		mode := srcStat.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if err := os.Chmod(dstPathName, mode); err != nil {
+			return err
+		}
+	}
+	if attrs&AttrTimes != 0 {
+		if atime, mtime, err := fileTimes(srcPathName); err == nil {
+			setFileTimes(dstPathName, atime, mtime)
+		}
+	}
+
+	return nil
+}
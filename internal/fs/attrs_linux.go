@@ -0,0 +1,67 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+)
+
+//copyOwner меняет владельца и группу файла на владельца и группу исходного файла. Сменить владельца
+//может только root, поэтому для остальных пользователей функция ничего не делает.
+func copyOwner(srcStat os.FileInfo, dstPathName string) error {
+	stat, ok := srcStat.Sys().(*syscall.Stat_t)
+	if !ok || os.Geteuid() != 0 {
+		return nil
+	}
+
+	return os.Lchown(dstPathName, int(stat.Uid), int(stat.Gid))
+}
+
+//copyXattrs копирует расширенные атрибуты файла. Если файловая система их не поддерживает, то функция ничего не делает.
+func copyXattrs(srcPathName, dstPathName string) error {
+	size, err := syscall.Listxattr(srcPathName, nil)
+	if err != nil || size == 0 {
+		return ignoreNotSupported(err)
+	}
+	buf := make([]byte, size)
+	if size, err = syscall.Listxattr(srcPathName, buf); err != nil {
+		return ignoreNotSupported(err)
+	}
+
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+
+		value, err := getXattr(srcPathName, string(name))
+		if err != nil {
+			return err
+		}
+		if err := syscall.Setxattr(dstPathName, string(name), value, 0); err != nil {
+			return ignoreNotSupported(err)
+		}
+	}
+
+	return nil
+}
+
+func getXattr(pathName, name string) ([]byte, error) {
+	size, err := syscall.Getxattr(pathName, name, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	value := make([]byte, size)
+	size, err = syscall.Getxattr(pathName, name, value)
+
+	return value[:size], err
+}
+
+func ignoreNotSupported(err error) error {
+	if errors.Is(err, syscall.ENOTSUP) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package fs
+
+import "os"
+
+//copyOwner не поддерживается на этой платформе.
+func copyOwner(srcStat os.FileInfo, dstPathName string) error {
+	return nil
+}
+
+//copyXattrs не поддерживается на этой платформе.
+func copyXattrs(srcPathName, dstPathName string) error {
+	return nil
+}
@@ -0,0 +1,77 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+//Режимы отслеживания каталога
+const (
+	WatchModePoll    = "poll"
+	WatchModeInotify = "inotify"
+	WatchModeAuto    = "auto"
+)
+
+//Backend источник сигналов о том, что содержимое отслеживаемого каталога нужно перечитать.
+type Backend interface {
+	//Run отправляет сигналы в канал notify до тех пор, пока не будет отменён ctx (в этом случае возвращается nil)
+	//или не возникнет ошибка, не позволяющая продолжить отслеживание.
+	Run(ctx context.Context, notify chan<- struct{}) error
+}
+
+//NewBackend возвращает бэкенд для указанного режима отслеживания. В режиме WatchModeAuto используется
+//inotify, если он поддерживается платформой, иначе - периодический опрос каталога.
+func NewBackend(mode string, dirReader DirReader, pollInterval time.Duration) (Backend, error) {
+	switch mode {
+	case WatchModePoll, "":
+		return NewPollBackend(pollInterval), nil
+	case WatchModeInotify:
+		return NewInotifyBackend(dirReader, pollInterval)
+	case WatchModeAuto:
+		if backend, err := NewInotifyBackend(dirReader, pollInterval); err == nil {
+			return backend, nil
+		}
+		return NewPollBackend(pollInterval), nil
+	default:
+		return nil, fmt.Errorf("unknown watch mode '%s'", mode)
+	}
+}
+
+//pollBackend периодически сигнализирует о необходимости перечитать каталог.
+type pollBackend struct {
+	pollInterval time.Duration
+}
+
+//NewPollBackend возвращает бэкенд, опрашивающий каталог с заданным интервалом.
+func NewPollBackend(pollInterval time.Duration) Backend {
+	return &pollBackend{pollInterval: pollInterval}
+}
+
+func (b *pollBackend) Run(ctx context.Context, notify chan<- struct{}) error {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	isTickerReset := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			signal(notify)
+
+			if !isTickerReset {
+				ticker.Reset(b.pollInterval)
+				isTickerReset = true
+			}
+		}
+	}
+}
+
+//signal отправляет сигнал без блокировки: если предыдущий сигнал ещё не обработан, то новый не нужен.
+func signal(notify chan<- struct{}) {
+	select {
+	case notify <- struct{}{}:
+	default:
+	}
+}
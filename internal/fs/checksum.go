@@ -0,0 +1,96 @@
+package fs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+//HashAlgorithm алгоритм вычисления контрольной суммы при проверке скопированного файла.
+type HashAlgorithm int
+
+//Алгоритмы вычисления контрольной суммы
+const (
+	HashNone HashAlgorithm = iota
+	HashSHA256
+	HashCRC32
+	HashXXHash
+)
+
+func (a HashAlgorithm) String() string {
+	switch a {
+	case HashNone:
+		return "none"
+	case HashSHA256:
+		return "sha256"
+	case HashCRC32:
+		return "crc32"
+	case HashXXHash:
+		return "xxhash"
+	default:
+		return "unknown"
+	}
+}
+
+//ParseHashAlgorithm возвращает алгоритм вычисления контрольной суммы по его названию.
+//Пустая строка соответствует HashNone.
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	if name == "" {
+		return HashNone, nil
+	}
+	for _, alg := range []HashAlgorithm{HashNone, HashSHA256, HashCRC32, HashXXHash} {
+		if alg.String() == name {
+			return alg, nil
+		}
+	}
+
+	return HashNone, fmt.Errorf("unknown hash algorithm '%s'", name)
+}
+
+func (a HashAlgorithm) new() hash.Hash {
+	switch a {
+	case HashSHA256:
+		return sha256.New()
+	case HashCRC32:
+		return crc32.NewIEEE()
+	case HashXXHash:
+		return xxhash.New()
+	default:
+		return nil
+	}
+}
+
+//checksum вычисляет контрольную сумму содержимого файла.
+func checksum(pathName string, alg HashAlgorithm) ([]byte, error) {
+	file, err := os.Open(pathName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hasher := alg.new()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return nil, err
+	}
+
+	return hasher.Sum(nil), nil
+}
+
+//verifyChecksum сравнивает контрольную сумму, вычисленную при копировании, с контрольной суммой файла на диске.
+func verifyChecksum(pathName string, alg HashAlgorithm, expected []byte) error {
+	actual, err := checksum(pathName, alg)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(expected, actual) {
+		return fmt.Errorf("%s checksum of the file '%s' is %x, expected %x: %w", alg, pathName, actual, expected, ErrChecksumMismatch)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//ConflictPolicy способ разрешения конфликта, когда в новом расположении уже есть файл с таким же именем.
+type ConflictPolicy int
+
+//Способы разрешения конфликтов
+const (
+	//ConflictFail завершает операцию с ошибкой ErrAlreadyExists
+	ConflictFail ConflictPolicy = iota
+	//ConflictSkip пропускает файл (операция завершается с ошибкой ErrSkipped)
+	ConflictSkip
+	//ConflictOverwrite перезаписывает существующий файл
+	ConflictOverwrite
+	//ConflictRename добавляет к имени нового файла числовой суффикс: "name (1).ext"
+	ConflictRename
+	//ConflictRenameTimestamp добавляет к имени нового файла текущее время: "name_20060102T150405.ext"
+	ConflictRenameTimestamp
+	//ConflictKeepNewer перезаписывает существующий файл, если он старше нового, иначе пропускает файл
+	ConflictKeepNewer
+	//ConflictKeepLarger перезаписывает существующий файл, если он меньше нового, иначе пропускает файл
+	ConflictKeepLarger
+)
+
+var conflictPolicyNames = map[ConflictPolicy]string{
+	ConflictFail:            "fail",
+	ConflictSkip:            "skip",
+	ConflictOverwrite:       "overwrite",
+	ConflictRename:          "rename",
+	ConflictRenameTimestamp: "rename-timestamp",
+	ConflictKeepNewer:       "keep-newer",
+	ConflictKeepLarger:      "keep-larger",
+}
+
+func (p ConflictPolicy) String() string {
+	if name, ok := conflictPolicyNames[p]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+//ParseConflictPolicy возвращает способ разрешения конфликтов по его названию.
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	for policy, policyName := range conflictPolicyNames {
+		if policyName == name {
+			return policy, nil
+		}
+	}
+
+	return ConflictFail, fmt.Errorf("unknown conflict policy '%s'", name)
+}
+
+//ResolveConflict возвращает путь, по которому нужно записать копию файла src, если предполагалось записать
+//её по пути dstPathName. Если overwrite равен true, то существующий по этому пути файл нужно перезаписать.
+//Если файл записывать не нужно, то возвращается ошибка ErrSkipped.
+func ResolveConflict(src *File, dstPathName string, policy ConflictPolicy) (pathName string, overwrite bool, err error) {
+	if exists := isExists(dstPathName); !exists {
+		return dstPathName, false, nil
+	}
+
+	switch policy {
+	case ConflictSkip:
+		return "", false, fmt.Errorf("file '%s' already exists: %w", dstPathName, ErrSkipped)
+	case ConflictOverwrite:
+		return dstPathName, true, nil
+	case ConflictRename:
+		return numberedPathName(dstPathName, ""), false, nil
+	case ConflictRenameTimestamp:
+		return numberedPathName(dstPathName, "_"+time.Now().Format("20060102T150405")), false, nil
+	case ConflictKeepNewer, ConflictKeepLarger:
+		srcStat, err := os.Stat(src.AbsolutePath())
+		if err != nil {
+			return "", false, err
+		}
+		dstStat, err := os.Stat(dstPathName)
+		if err != nil {
+			return "", false, err
+		}
+
+		if (policy == ConflictKeepNewer && srcStat.ModTime().After(dstStat.ModTime())) ||
+			(policy == ConflictKeepLarger && srcStat.Size() > dstStat.Size()) {
+			return dstPathName, true, nil
+		}
+		return "", false, fmt.Errorf("file '%s' already exists and is kept (%s): %w", dstPathName, policy, ErrSkipped)
+	default:
+		return "", false, fmt.Errorf("file '%s' already exists: %w", dstPathName, ErrAlreadyExists)
+	}
+}
+
+//link создаёт жёсткую ссылку (заменяется в тестах).
+var link = os.Link
+
+//placement путь, по которому записывается копия файла.
+type placement struct {
+	src *File
+	//target путь, по которому предполагалось записать копию
+	target string
+	policy ConflictPolicy
+	//pathName путь после разрешения конфликта имён
+	pathName  string
+	overwrite bool
+}
+
+//resolvePlacement разрешает конфликт имён для копии файла src, которую предполагалось записать по пути target.
+func resolvePlacement(src *File, target string, policy ConflictPolicy) (*placement, error) {
+	p := &placement{src: src, target: target, policy: policy}
+	if err := p.resolve(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *placement) resolve() (err error) {
+	p.pathName, p.overwrite, err = ResolveConflict(p.src, p.target, p.policy)
+	return
+}
+
+//place переносит файл pathName по итоговому пути. Если существующий файл перезаписывать не нужно, то итоговое
+//имя занимается атомарно (жёсткой ссылкой): если файл с таким именем появился уже после разрешения конфликта
+//(например, его записал параллельный обработчик), то конфликт разрешается заново. На файловых системах
+//без жёстких ссылок файл просто переименовывается.
+func (p *placement) place(pathName string) error {
+	for !p.overwrite {
+		err := link(pathName, p.pathName)
+		if err == nil {
+			if err := os.Remove(pathName); err != nil {
+				os.Remove(p.pathName)
+				return err
+			}
+			return nil
+		}
+		if isCrossDeviceError(err) {
+			return err
+		}
+		if !os.IsExist(err) {
+			break
+		}
+		if err := p.resolve(); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(pathName, p.pathName)
+}
+
+//numberedPathName добавляет к имени файла суффикс, а если файл с таким именем существует - ещё и числовой суффикс.
+func numberedPathName(pathName, suffix string) string {
+	ext := filepath.Ext(pathName)
+	base := strings.TrimSuffix(pathName, ext) + suffix
+
+	if candidate := base + ext; suffix != "" && !isExists(candidate) {
+		return candidate
+	}
+	for i := 1; ; i++ {
+		if candidate := fmt.Sprintf("%s (%d)%s", base, i, ext); !isExists(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+)
+
+const copyBufferSize = 100 << 20 //100Mb
+
+//bufferPool позволяет не выделять буфер копирования заново при каждом копировании.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, copyBufferSize)
+		return &buf
+	},
+}
+
+//copyContent копирует содержимое файла src в dst. Если при копировании не нужно ни вычислять контрольную
+//сумму, ни ограничивать скорость, ни сообщать о ходе копирования, то используются средства ОС (см. copyFile),
+//иначе данные копируются через буфер. Возвращает контрольную сумму, вычисленную при копировании (если она нужна).
+func copyContent(ctx context.Context, dst, src *os.File, file *File, options copyOptions) ([]byte, error) {
+	if options.verify == HashNone && options.limiter == nil && options.progress == nil {
+		return nil, copyFile(ctx, dst, src)
+	}
+
+	var reader io.Reader = &contextReader{ctx: ctx, r: src}
+	var hasher hash.Hash
+	if options.verify != HashNone {
+		hasher = options.verify.new()
+		reader = io.TeeReader(reader, hasher)
+	}
+
+	var writer io.Writer = dst
+	if options.limiter != nil {
+		writer = &rateLimitedWriter{ctx: ctx, w: writer, limiter: options.limiter}
+	}
+	var progress *progressWriter
+	if options.progress != nil {
+		var size int64
+		if stat, err := src.Stat(); err == nil {
+			size = stat.Size()
+		}
+		progress = newProgressWriter(writer, file, size, options.progressInterval, options.progress)
+		writer = progress
+	}
+
+	if err := copy(writer, reader); err != nil {
+		return nil, err
+	}
+	if progress != nil {
+		progress.finish()
+	}
+	if hasher != nil {
+		return hasher.Sum(nil), nil
+	}
+
+	return nil, nil
+}
+
+//contextReader прерывает чтение при отмене ctx.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
+func copy(dst io.Writer, src io.Reader) error {
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+
+	if _, err := io.CopyBuffer(dst, src, *buf); err != nil {
+		return fmt.Errorf("%s: %w", err.Error(), ErrCopy)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+//fastCopyChunk максимальный объём данных, копируемый одним вызовом copy_file_range (между проверками отмены ctx).
+const fastCopyChunk = 64 << 20 //64Mb
+
+//значения whence для lseek, позволяющие найти области файла с данными и "дыры" (linux/fs.h)
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+//copyFile копирует содержимое файла средствами ядра. Сначала делается попытка создать reflink (FICLONE),
+//при которой данные не копируются вовсе (btrfs, xfs). Иначе копируются только области файла с данными
+//(SEEK_DATA/SEEK_HOLE), поэтому "дыры" разреженного файла сохраняются. Сами данные копируются вызовом
+//copy_file_range, а если он не поддерживается (например, между разными файловыми системами
+//на старых ядрах) - через буфер.
+func copyFile(ctx context.Context, dst, src *os.File) error {
+	if err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())); err == nil {
+		return nil
+	}
+
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	size := stat.Size()
+
+	useCopyFileRange := true
+	for offset := int64(0); offset < size; {
+		dataStart, dataEnd, err := nextDataRange(src, offset, size)
+		if err != nil {
+			return err
+		}
+		if dataStart >= size {
+			break
+		}
+
+		if err := copyRange(ctx, dst, src, dataStart, dataEnd, &useCopyFileRange); err != nil {
+			return err
+		}
+		offset = dataEnd
+	}
+
+	//хвостовая "дыра" не создаётся при копировании областей с данными
+	return dst.Truncate(size)
+}
+
+//nextDataRange возвращает границы ближайшей к offset области файла с данными. Если файловая система
+//не поддерживает поиск "дыр", то весь остаток файла считается данными.
+func nextDataRange(file *os.File, offset, size int64) (start, end int64, err error) {
+	fd := int(file.Fd())
+
+	start, err = unix.Seek(fd, offset, seekData)
+	if errors.Is(err, unix.ENXIO) {
+		return size, size, nil
+	}
+	if err != nil {
+		return offset, size, nil
+	}
+
+	end, err = unix.Seek(fd, start, seekHole)
+	if err != nil || end > size {
+		end = size
+	}
+
+	return start, end, nil
+}
+
+//copyRange копирует область файла [start, end) в ту же область файла dst.
+func copyRange(ctx context.Context, dst, src *os.File, start, end int64, useCopyFileRange *bool) error {
+	for offset := start; offset < end; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		length := end - offset
+		if length > fastCopyChunk {
+			length = fastCopyChunk
+		}
+
+		if *useCopyFileRange {
+			srcOffset, dstOffset := offset, offset
+			n, err := unix.CopyFileRange(int(src.Fd()), &srcOffset, int(dst.Fd()), &dstOffset, int(length), 0)
+			if err == nil {
+				if n == 0 {
+					//copy_file_range может ничего не скопировать и до конца файла (например, в procfs или
+					//если файл стал короче) - конец файла определяется уже при копировании через буфер
+					*useCopyFileRange = false
+					continue
+				}
+				offset += int64(n)
+				continue
+			}
+			if !isCopyFileRangeUnsupported(err) {
+				return fmt.Errorf("%s: %w", err.Error(), ErrCopy)
+			}
+			*useCopyFileRange = false
+		}
+
+		n, err := copyRangeBuffered(dst, src, offset, length)
+		offset += n
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", err.Error(), ErrCopy)
+		}
+	}
+
+	return nil
+}
+
+func copyRangeBuffered(dst, src *os.File, offset, length int64) (int64, error) {
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+
+	chunk := *buf
+	if int64(len(chunk)) > length {
+		chunk = chunk[:length]
+	}
+
+	n, err := src.ReadAt(chunk, offset)
+	if n > 0 {
+		if _, err := dst.WriteAt(chunk[:n], offset); err != nil {
+			return 0, err
+		}
+	}
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return int64(n), err
+}
+
+func isCopyFileRangeUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EXDEV) || errors.Is(err, unix.EOPNOTSUPP) ||
+		errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EPERM)
+}
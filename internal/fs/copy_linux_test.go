@@ -0,0 +1,92 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestFile_MoveToContextCanceledCopy(t *testing.T) {
+	srcPathName, dstDirName, cleanup := copyFixture(t, make([]byte, 3*progressChunk))
+	defer cleanup()
+
+	//исходный файл будто бы находится на другой файловой системе, поэтому он копируется
+	defer func(original func(oldname, newname string) error) { link = original }(link)
+	link = func(oldname, newname string) error {
+		if oldname == srcPathName {
+			return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: unix.EXDEV}
+		}
+		return os.Link(oldname, newname)
+	}
+
+	//отменяем перемещение после первого сообщения о ходе копирования
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := (&File{PathName: srcPathName}).MoveToContext(ctx, dstDirName, WithProgress(0, func(Progress) { cancel() }))
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, isExists(srcPathName))
+	assert.False(t, isExists(filepath.Join(dstDirName, "file")))
+	assert.Empty(t, partialFiles(t, dstDirName))
+
+	strategy, err := (&File{PathName: srcPathName}).MoveTo(dstDirName)
+	assert.Nil(t, err)
+	assert.Equal(t, MoveCopied, strategy)
+	assert.False(t, isExists(srcPathName))
+}
+
+func TestFile_CopyToSparse(t *testing.T) {
+	srcPathName, dstDirName, cleanup := copyFixture(t, nil)
+	defer cleanup()
+
+	//файл с "дырами" в начале, в середине и в конце
+	src, err := os.OpenFile(srcPathName, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const size = 8 << 20
+	for _, offset := range []int64{1 << 20, 5 << 20} {
+		if _, err := src.WriteAt([]byte("data"), offset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := src.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	src.Close()
+
+	dstFile, err := (&File{PathName: srcPathName}).CopyTo(dstDirName)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	expected, err := ioutil.ReadFile(srcPathName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ioutil.ReadFile(dstFile.AbsolutePath())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(size), int64(len(actual)))
+	assert.True(t, bytes.Equal(expected, actual))
+
+	//"дыры" сохраняются, если их поддерживает файловая система
+	if blocks(t, srcPathName)*512 >= size {
+		t.Skip("the file system does not support sparse files")
+	}
+	assert.Less(t, blocks(t, dstFile.AbsolutePath())*512, int64(size/4))
+}
+
+//blocks возвращает количество 512-байтных блоков, занимаемых файлом.
+func blocks(t *testing.T, pathName string) int64 {
+	var stat unix.Stat_t
+	if err := unix.Stat(pathName, &stat); err != nil {
+		t.Fatal(err)
+	}
+
+	return stat.Blocks
+}
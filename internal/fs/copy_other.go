@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package fs
+
+import (
+	"context"
+	"os"
+)
+
+//copyFile копирует содержимое файла через буфер.
+func copyFile(ctx context.Context, dst, src *os.File) error {
+	return copy(dst, &contextReader{ctx: ctx, r: src})
+}
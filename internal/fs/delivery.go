@@ -0,0 +1,133 @@
+package fs
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+//DeliveryPolicy стратегия доставки срезов файлов в канал событий, когда получатель не успевает их обрабатывать.
+type DeliveryPolicy int
+
+//Стратегии доставки
+const (
+	//DeliverCoalesce объединяет все ожидающие доставки срезы в один.
+	DeliverCoalesce DeliveryPolicy = iota
+	//DeliverBlock приостанавливает чтение каталога до тех пор, пока получатель не заберёт срез.
+	DeliverBlock
+	//DeliverDropOldest хранит ограниченное количество срезов, при переполнении отбрасывает самый старый.
+	DeliverDropOldest
+)
+
+func (p DeliveryPolicy) String() string {
+	switch p {
+	case DeliverCoalesce:
+		return "coalesce"
+	case DeliverBlock:
+		return "block"
+	case DeliverDropOldest:
+		return "drop-oldest"
+	default:
+		return "unknown"
+	}
+}
+
+//ParseDeliveryPolicy возвращает стратегию доставки по её названию.
+func ParseDeliveryPolicy(name string) (DeliveryPolicy, error) {
+	for _, policy := range []DeliveryPolicy{DeliverCoalesce, DeliverBlock, DeliverDropOldest} {
+		if policy.String() == name {
+			return policy, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown delivery policy '%s'", name)
+}
+
+//DeliveryStats счётчики доставки срезов файлов.
+type DeliveryStats struct {
+	//Delivered количество срезов, переданных получателю
+	Delivered uint64
+	//Dropped количество срезов, отброшенных из-за переполнения буфера
+	Dropped uint64
+	//Coalesced количество срезов, объединённых с ожидающими доставки
+	Coalesced uint64
+}
+
+//eventQueue очередь срезов, ожидающих доставки получателю.
+type eventQueue struct {
+	policy  DeliveryPolicy
+	size    int
+	batches [][]*File
+
+	delivered, dropped, coalesced uint64
+}
+
+func newEventQueue(policy DeliveryPolicy, size int) *eventQueue {
+	if size < 1 {
+		size = 1
+	}
+
+	return &eventQueue{
+		policy: policy,
+		size:   size,
+	}
+}
+
+func (q *eventQueue) push(batch []*File) {
+	switch {
+	case len(q.batches) == 0:
+		q.batches = append(q.batches, batch)
+	case q.policy == DeliverCoalesce:
+		q.batches[0] = coalesce(q.batches[0], batch)
+		atomic.AddUint64(&q.coalesced, 1)
+	case q.policy == DeliverDropOldest && len(q.batches) >= q.size:
+		q.batches = append(q.batches[1:], batch)
+		atomic.AddUint64(&q.dropped, 1)
+	default:
+		q.batches = append(q.batches, batch)
+	}
+}
+
+func (q *eventQueue) front() ([]*File, bool) {
+	if len(q.batches) == 0 {
+		return nil, false
+	}
+
+	return q.batches[0], true
+}
+
+func (q *eventQueue) pop() {
+	q.batches[0] = nil
+	q.batches = q.batches[1:]
+	atomic.AddUint64(&q.delivered, 1)
+}
+
+//blocked возвращает true, если до доставки ожидающих срезов каталог читать не нужно.
+func (q *eventQueue) blocked() bool {
+	return q.policy == DeliverBlock && len(q.batches) > 0
+}
+
+func (q *eventQueue) stats() DeliveryStats {
+	return DeliveryStats{
+		Delivered: atomic.LoadUint64(&q.delivered),
+		Dropped:   atomic.LoadUint64(&q.dropped),
+		Coalesced: atomic.LoadUint64(&q.coalesced),
+	}
+}
+
+//coalesce объединяет ожидающий срез с новым. Файлы из ожидающего среза, которых уже нет на диске
+//(например, их успел обработать получатель), отбрасываются.
+func coalesce(pending, batch []*File) []*File {
+	seen := make(map[string]bool, len(batch))
+	for _, file := range batch {
+		seen[file.AbsolutePath()] = true
+	}
+
+	res := make([]*File, 0, len(pending)+len(batch))
+	for _, file := range pending {
+		if !seen[file.AbsolutePath()] && isExists(file.AbsolutePath()) {
+			res = append(res, file)
+		}
+	}
+
+	return append(res, batch...)
+}
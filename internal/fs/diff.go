@@ -0,0 +1,117 @@
+package fs
+
+import (
+	"os"
+	"sort"
+)
+
+//EventType тип изменения содержимого каталога.
+type EventType int
+
+//Типы изменений
+const (
+	Created EventType = iota + 1
+	Modified
+	Removed
+	Renamed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Created:
+		return "created"
+	case Modified:
+		return "modified"
+	case Removed:
+		return "removed"
+	case Renamed:
+		return "renamed"
+	default:
+		return "unknown"
+	}
+}
+
+//Event изменение содержимого отслеживаемого каталога.
+type Event struct {
+	Type EventType
+	//File файл, к которому относится изменение (для Renamed - файл с новым именем)
+	File *File
+	//OldFile файл с прежним именем (заполняется только для Renamed)
+	OldFile *File
+	//OldInfo прежнее состояние файла (nil для Created)
+	OldInfo os.FileInfo
+	//NewInfo новое состояние файла (nil для Removed)
+	NewInfo os.FileInfo
+}
+
+//SnapshotEntry элемент содержимого каталога вместе с его состоянием на момент чтения.
+type SnapshotEntry struct {
+	File *File
+	Info os.FileInfo
+}
+
+//Snapshot состояние содержимого каталога (ключ - полный путь к элементу).
+type Snapshot map[string]SnapshotEntry
+
+func newSnapshot(entries []SnapshotEntry) Snapshot {
+	snapshot := make(Snapshot, len(entries))
+	for _, entry := range entries {
+		snapshot[entry.File.AbsolutePath()] = entry
+	}
+
+	return snapshot
+}
+
+//Diff сравнивает два состояния каталога и возвращает список изменений, упорядоченный по пути к файлу.
+//Пара "удалён/создан", относящаяся к одному и тому же файлу (см. os.SameFile) с неизменными размером
+//и временем модификации, считается переименованием (сравнение состояния защищает от повторного
+//использования номера inode только что удалённого файла).
+func Diff(prev, curr Snapshot) (res []Event) {
+	var removed []SnapshotEntry
+	for pathName, oldEntry := range prev {
+		newEntry, ok := curr[pathName]
+		if !ok {
+			removed = append(removed, oldEntry)
+			continue
+		}
+		if isModified(oldEntry.Info, newEntry.Info) {
+			res = append(res, Event{Type: Modified, File: newEntry.File, OldInfo: oldEntry.Info, NewInfo: newEntry.Info})
+		}
+	}
+
+	var created []SnapshotEntry
+	for pathName, newEntry := range curr {
+		if _, ok := prev[pathName]; !ok {
+			created = append(created, newEntry)
+		}
+	}
+
+	for _, oldEntry := range removed {
+		renamed := false
+		for i, newEntry := range created {
+			if os.SameFile(oldEntry.Info, newEntry.Info) && !isModified(oldEntry.Info, newEntry.Info) {
+				res = append(res, Event{Type: Renamed, File: newEntry.File, OldFile: oldEntry.File, OldInfo: oldEntry.Info, NewInfo: newEntry.Info})
+				created = append(created[:i], created[i+1:]...)
+				renamed = true
+				break
+			}
+		}
+		if !renamed {
+			res = append(res, Event{Type: Removed, File: oldEntry.File, OldInfo: oldEntry.Info})
+		}
+	}
+
+	for _, newEntry := range created {
+		res = append(res, Event{Type: Created, File: newEntry.File, NewInfo: newEntry.Info})
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].File.AbsolutePath() < res[j].File.AbsolutePath()
+	})
+
+	return
+}
+
+func isModified(prev, curr os.FileInfo) bool {
+	return prev.Size() != curr.Size() || !prev.ModTime().Equal(curr.ModTime()) || prev.Mode() != curr.Mode()
+}
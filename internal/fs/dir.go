@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 //FilterFunc если функция возвращает true, то данный экземпляр содержимого каталога должен содержаться в выборке.
@@ -14,10 +15,22 @@ var defaultFilterFunc = func(fileInfo os.FileInfo) bool {
 	return true
 }
 
+//WalkOptions параметры рекурсивного обхода каталога.
+type WalkOptions struct {
+	//MaxDepth максимальная глубина вложенности просматриваемых подкаталогов (0 - без ограничений).
+	MaxDepth int
+	//SkipHidden пропускать скрытые подкаталоги (имя которых начинается с точки).
+	SkipHidden bool
+	//SkipSymlinks не заходить в подкаталоги, являющиеся символическими ссылками.
+	SkipSymlinks bool
+}
+
 //DirReader представляет собой просмотрщик содержимого каталога, указанного в поле Path.
 type DirReader struct {
-	path   string
-	filter FilterFunc
+	path      string
+	filter    FilterFunc
+	recursive bool
+	walk      WalkOptions
 }
 
 //NewDirReader возвращает настроенный экземпляр DirReader
@@ -36,26 +49,158 @@ func NewDirReaderWithFilter(path string, filter FilterFunc) DirReader {
 	}
 }
 
-//ReadWithFilter возвращает содержимое каталога.
+//NewRecursiveDirReader возвращает экземпляр DirReader, который просматривает также и подкаталоги.
+//Фильтр применяется только к отбираемым элементам, обход подкаталогов от него не зависит.
+func NewRecursiveDirReader(path string, filter FilterFunc, opts WalkOptions) DirReader {
+	return DirReader{
+		path:      path,
+		filter:    filter,
+		recursive: true,
+		walk:      opts,
+	}
+}
+
+//Path возвращает путь к просматриваемому каталогу.
+func (r DirReader) Path() string {
+	return r.path
+}
+
+//Recursive возвращает true, если просматриваются также и подкаталоги.
+func (r DirReader) Recursive() bool {
+	return r.recursive
+}
+
+//Read возвращает содержимое каталога.
 func (r DirReader) Read() (res []*File, err error) {
-	if err = r.validate(); err != nil {
+	entries, err := r.readEntries()
+	if err != nil {
 		return
 	}
-	entries, err := ioutil.ReadDir(r.path)
+
+	for _, entry := range entries {
+		res = append(res, entry.File)
+	}
+
+	return
+}
+
+//Snapshot возвращает состояние содержимого каталога, пригодное для сравнения функцией Diff.
+func (r DirReader) Snapshot() (Snapshot, error) {
+	entries, err := r.readEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	return newSnapshot(entries), nil
+}
+
+func (r DirReader) readEntries() ([]SnapshotEntry, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	if !r.recursive {
+		return r.readDir(r.path, 1, nil)
+	}
+
+	visited := make(map[string]bool)
+	if realPath, err := filepath.EvalSymlinks(r.path); err == nil {
+		visited[realPath] = true
+	}
+
+	return r.readDir(r.path, 1, visited)
+}
+
+func (r DirReader) readDir(path string, depth int, visited map[string]bool) (res []SnapshotEntry, err error) {
+	entries, err := ioutil.ReadDir(path)
 	if err != nil {
 		return
 	}
 
 	for _, entry := range entries {
+		entryPathName := filepath.Join(path, entry.Name())
+
 		if ok := r.filter(entry); ok {
-			entryPathName := filepath.Join(r.path, entry.Name())
-			res = append(res, &File{entryPathName})
+			res = append(res, SnapshotEntry{File: &File{PathName: entryPathName, root: r.path}, Info: entry})
+		}
+
+		if r.recursive && r.shouldDescend(entryPathName, entry, depth, visited) {
+			var nested []SnapshotEntry
+			if nested, err = r.readDir(entryPathName, depth+1, visited); err != nil {
+				return nil, err
+			}
+			res = append(res, nested...)
 		}
 	}
 
 	return
 }
 
+//shouldDescend определяет, нужно ли заходить в подкаталог. Для подкаталогов, являющихся символическими
+//ссылками, запоминается реальный путь, чтобы не зациклиться на ссылках, указывающих на родительские каталоги.
+func (r DirReader) shouldDescend(pathName string, entry os.FileInfo, depth int, visited map[string]bool) bool {
+	if r.walk.MaxDepth > 0 && depth >= r.walk.MaxDepth {
+		return false
+	}
+	if r.walk.SkipHidden && strings.HasPrefix(entry.Name(), ".") {
+		return false
+	}
+
+	if entry.Mode()&os.ModeSymlink != 0 {
+		if r.walk.SkipSymlinks {
+			return false
+		}
+		if dir, _ := isDirectory(pathName); !dir {
+			return false
+		}
+	} else if !entry.IsDir() {
+		return false
+	}
+
+	realPath, err := filepath.EvalSymlinks(pathName)
+	if err != nil || visited[realPath] {
+		return false
+	}
+	visited[realPath] = true
+
+	return true
+}
+
+//dirs возвращает список каталогов, содержимое которых просматривает DirReader.
+func (r DirReader) dirs() ([]string, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	res := []string{r.path}
+	if !r.recursive {
+		return res, nil
+	}
+
+	visited := make(map[string]bool)
+	if realPath, err := filepath.EvalSymlinks(r.path); err == nil {
+		visited[realPath] = true
+	}
+
+	var walk func(path string, depth int)
+	walk = func(path string, depth int) {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			entryPathName := filepath.Join(path, entry.Name())
+			if r.shouldDescend(entryPathName, entry, depth, visited) {
+				res = append(res, entryPathName)
+				walk(entryPathName, depth+1)
+			}
+		}
+	}
+	walk(r.path, 1)
+
+	return res, nil
+}
+
 func (r DirReader) validate() error {
 	if exists := isExists(r.path); !exists {
 		return fmt.Errorf("directory '%s' is not exists: %w", r.path, ErrNotExists)
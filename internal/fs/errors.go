@@ -4,10 +4,13 @@ import "errors"
 
 //Ошибки
 var (
-	ErrCopy          = errors.New("can not copy")
-	ErrBlocked       = errors.New("blocked by another process")
-	ErrAlreadyExists = errors.New("already exists")
-	ErrNotExists     = errors.New("not exists")
-	ErrNotRegular    = errors.New("not regular")
-	ErrNotDirectory  = errors.New("not directory")
+	ErrCopy             = errors.New("can not copy")
+	ErrBlocked          = errors.New("blocked by another process")
+	ErrAlreadyExists    = errors.New("already exists")
+	ErrNotExists        = errors.New("not exists")
+	ErrNotRegular       = errors.New("not regular")
+	ErrNotDirectory     = errors.New("not directory")
+	ErrNotSupported     = errors.New("not supported")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrSkipped          = errors.New("skipped")
 )
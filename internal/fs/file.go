@@ -1,8 +1,8 @@
 package fs
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"time"
@@ -11,6 +11,8 @@ import (
 //File абстракция над файлом файловой системы
 type File struct {
 	PathName string
+	//root каталог, относительно которого был найден файл (заполняется DirReader-ом)
+	root string
 }
 
 //Name возвращает имя файла
@@ -23,6 +25,26 @@ func (f *File) AbsolutePath() string {
 	return f.PathName
 }
 
+//RelativePath возвращает путь к файлу относительно каталога, в котором он был найден.
+//Если каталог неизвестен, то возвращается имя файла.
+func (f *File) RelativePath() string {
+	if f.root == "" {
+		return f.Name()
+	}
+
+	relPath, err := filepath.Rel(f.root, f.PathName)
+	if err != nil {
+		return f.Name()
+	}
+
+	return relPath
+}
+
+//RelativeDir возвращает каталог файла относительно каталога, в котором он был найден ("." для файлов верхнего уровня).
+func (f *File) RelativeDir() string {
+	return filepath.Dir(f.RelativePath())
+}
+
 func (f *File) String() string {
 	return f.AbsolutePath()
 }
@@ -61,23 +83,39 @@ func (f *File) Delete() error {
 }
 
 //CopyTo копирует файл в новое расположение. Если операция копирования прошла удачно, то возвращается указатель на новый файл.
-func (f *File) CopyTo(path string) (*File, error) {
-	dstFile := &File{PathName: filepath.Join(path, f.Name())}
+//Копирование выполняется во временный файл (см. IsPartialFile), который после сброса данных на диск
+//переносится под итоговое имя. Поэтому под итоговым именем никогда не окажется недописанный файл.
+//Если в новом расположении уже есть файл с таким же именем, то он обрабатывается согласно WithConflictPolicy,
+//в том числе если файл появился во время копирования (например, его записал параллельный обработчик).
+//Без проверки контрольной суммы, ограничения скорости и сообщений о ходе копирования файл копируется
+//средствами ОС (reflink, copy_file_range) с сохранением "дыр" разреженных файлов.
+func (f *File) CopyTo(path string, opts ...CopyOption) (*File, error) {
+	return f.CopyToContext(context.Background(), path, opts...)
+}
 
-	//модифицируем время модификации и доступа в новом файле, на такие же значения, как в оригинальном
-	defer func() {
-		if atime, mtime, err := fileTimes(f.AbsolutePath()); err == nil {
-			setFileTimes(dstFile.AbsolutePath(), atime, mtime)
-		}
-	}()
+//CopyToContext аналогичен CopyTo, но прерывает копирование при отмене ctx. В этом случае недописанная
+//копия удаляется, а возвращаемая ошибка содержит ctx.Err().
+func (f *File) CopyToContext(ctx context.Context, path string, opts ...CopyOption) (*File, error) {
+	options := newCopyOptions(opts)
 
 	if err := f.validate(); err != nil {
 		return nil, err
 	}
 
-	if exists := isExists(dstFile.AbsolutePath()); exists {
-		return nil, fmt.Errorf("file '%s' already exists: %w", dstFile.AbsolutePath(), ErrAlreadyExists)
+	dst, err := resolvePlacement(f, options.targetPathName(f, path), options.conflict)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.copyTo(ctx, dst, options)
+}
+
+//copyTo копирует файл по пути dst.
+func (f *File) copyTo(ctx context.Context, dst *placement, options copyOptions) (*File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+
 	if locked := isFileLocked(f.AbsolutePath()); locked {
 		return nil, fmt.Errorf("file '%s' is blocked: %w", f.AbsolutePath(), ErrBlocked)
 	}
@@ -88,45 +126,113 @@ func (f *File) CopyTo(path string) (*File, error) {
 	}
 	defer source.Close()
 
-	destination, err := os.Create(dstFile.AbsolutePath())
+	destination, err := createPartialFile(dst.pathName)
 	if err != nil {
 		return nil, err
 	}
 	defer destination.Close()
+	tmpPathName := destination.Name()
 
-	err = copy(destination, source)
+	sum, err := copyContent(ctx, destination, source, f, options)
+	if err == nil {
+		err = destination.Sync()
+	}
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && options.verify != HashNone {
+		err = verifyChecksum(tmpPathName, options.verify, sum)
+	}
 	if err != nil {
-		destination.Close()
-		dstFile.Delete()
+		os.Remove(tmpPathName)
 
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("copying of the file '%s' was canceled: %w", f.AbsolutePath(), ctxErr)
+		}
 		return nil, err
 	}
 
-	return dstFile, nil
+	//переносим на новый файл атрибуты оригинального (по умолчанию - время модификации и доступа)
+	if err := preserveAttributes(f.AbsolutePath(), tmpPathName, options.preserve); err != nil {
+		os.Remove(tmpPathName)
+
+		return nil, err
+	}
+
+	if err := dst.place(tmpPathName); err != nil {
+		os.Remove(tmpPathName)
+
+		return nil, err
+	}
+	syncDir(filepath.Dir(dst.pathName))
+
+	return &File{PathName: dst.pathName}, nil
 }
 
-func copy(dst io.Writer, src io.Reader) error {
-	bufferSize := 100 << 20 //100Mb
-	buf := make([]byte, bufferSize)
+//MoveStrategy способ, которым был перемещён файл.
+type MoveStrategy int
+
+//Способы перемещения
+const (
+	//MoveRenamed файл переименован в пределах одной файловой системы
+	MoveRenamed MoveStrategy = iota + 1
+	//MoveCopied файл скопирован в новое расположение, после чего исходный файл удалён
+	MoveCopied
+)
 
-	if _, err := io.CopyBuffer(dst, src, buf); err != nil {
-		return fmt.Errorf("%s: %w", err.Error(), ErrCopy)
+func (s MoveStrategy) String() string {
+	switch s {
+	case MoveRenamed:
+		return "rename"
+	case MoveCopied:
+		return "copy"
+	default:
+		return "unknown"
 	}
+}
 
-	return nil
+//MoveTo перемещает файл в новое расположение. Сначала делается попытка переименовать файл, и только если
+//исходное и новое расположение находятся на разных файловых системах, файл копируется с последующим удалением.
+//Конфликты имён разрешаются так же, как в CopyTo. Возвращает способ, которым файл был перемещён.
+func (f *File) MoveTo(path string, opts ...CopyOption) (MoveStrategy, error) {
+	return f.MoveToContext(context.Background(), path, opts...)
 }
 
-//MoveTo перемещает файл в новое расположение
-func (f *File) MoveTo(path string) error {
-	targetFile, err := f.CopyTo(path)
+//MoveToContext аналогичен MoveTo, но прерывает копирование при отмене ctx. В этом случае недописанная
+//копия удаляется, а исходный файл остаётся на месте.
+func (f *File) MoveToContext(ctx context.Context, path string, opts ...CopyOption) (MoveStrategy, error) {
+	options := newCopyOptions(opts)
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := f.validate(); err != nil {
+		return 0, err
+	}
+
+	dst, err := resolvePlacement(f, options.targetPathName(f, path), options.conflict)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	err = dst.place(f.AbsolutePath())
+	if err == nil {
+		f.PathName, f.root = dst.pathName, ""
+		return MoveRenamed, nil
+	}
+	if !isCrossDeviceError(err) {
+		return 0, err
+	}
+
+	targetFile, err := f.copyTo(ctx, dst, options)
+	if err != nil {
+		return 0, err
 	}
 
 	originalFile := *f
-	f.PathName = targetFile.AbsolutePath()
+	f.PathName, f.root = targetFile.AbsolutePath(), ""
 
-	return originalFile.Delete()
+	return MoveCopied, originalFile.Delete()
 }
 
 func (f *File) validate() error {
@@ -0,0 +1,168 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//RegexPrefix префикс шаблона, означающий, что шаблон является регулярным выражением (см. Pattern).
+const RegexPrefix = "re:"
+
+//And возвращает фильтр, который пропускает элемент, если его пропускают все указанные фильтры.
+func And(filters ...FilterFunc) FilterFunc {
+	return func(fileInfo os.FileInfo) bool {
+		for _, filter := range filters {
+			if !filter(fileInfo) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+//Or возвращает фильтр, который пропускает элемент, если его пропускает хотя бы один из указанных фильтров.
+func Or(filters ...FilterFunc) FilterFunc {
+	return func(fileInfo os.FileInfo) bool {
+		for _, filter := range filters {
+			if filter(fileInfo) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+//Not возвращает фильтр, который пропускает элемент, если его не пропускает указанный фильтр.
+func Not(filter FilterFunc) FilterFunc {
+	return func(fileInfo os.FileInfo) bool {
+		return !filter(fileInfo)
+	}
+}
+
+//Regular возвращает фильтр, который пропускает только обычные файлы.
+func Regular() FilterFunc {
+	return func(fileInfo os.FileInfo) bool {
+		return fileInfo.Mode().IsRegular()
+	}
+}
+
+//Partial возвращает фильтр, который пропускает только временные файлы копирования (см. IsPartialFile).
+func Partial() FilterFunc {
+	return func(fileInfo os.FileInfo) bool {
+		return IsPartialFile(fileInfo.Name())
+	}
+}
+
+//Glob возвращает фильтр, который пропускает элементы, имя которых соответствует шаблону (см. filepath.Match).
+func Glob(pattern string) (FilterFunc, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
+	}
+
+	return func(fileInfo os.FileInfo) bool {
+		matched, _ := filepath.Match(pattern, fileInfo.Name())
+		return matched
+	}, nil
+}
+
+//Regex возвращает фильтр, который пропускает элементы, имя которых соответствует регулярному выражению.
+func Regex(expr string) (FilterFunc, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression '%s': %w", expr, err)
+	}
+
+	return func(fileInfo os.FileInfo) bool {
+		return re.MatchString(fileInfo.Name())
+	}, nil
+}
+
+//Pattern возвращает фильтр по шаблону имени: если шаблон начинается с RegexPrefix, то остаток
+//считается регулярным выражением, иначе - glob-шаблоном.
+func Pattern(pattern string) (FilterFunc, error) {
+	if strings.HasPrefix(pattern, RegexPrefix) {
+		return Regex(strings.TrimPrefix(pattern, RegexPrefix))
+	}
+
+	return Glob(pattern)
+}
+
+//Extensions возвращает фильтр, который пропускает элементы с указанными расширениями
+//(без учёта регистра, точка в начале расширения необязательна).
+func Extensions(exts ...string) FilterFunc {
+	set := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		set["."+strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+
+	return func(fileInfo os.FileInfo) bool {
+		return set[strings.ToLower(filepath.Ext(fileInfo.Name()))]
+	}
+}
+
+//MinSize возвращает фильтр, который пропускает элементы размером не меньше size байт.
+func MinSize(size int64) FilterFunc {
+	return func(fileInfo os.FileInfo) bool {
+		return fileInfo.Size() >= size
+	}
+}
+
+//MaxSize возвращает фильтр, который пропускает элементы размером не больше size байт.
+func MaxSize(size int64) FilterFunc {
+	return func(fileInfo os.FileInfo) bool {
+		return fileInfo.Size() <= size
+	}
+}
+
+//MinAge возвращает фильтр, который пропускает элементы, изменённые не позднее, чем age назад.
+func MinAge(age time.Duration) FilterFunc {
+	return func(fileInfo os.FileInfo) bool {
+		return time.Since(fileInfo.ModTime()) >= age
+	}
+}
+
+//MaxAge возвращает фильтр, который пропускает элементы, изменённые не ранее, чем age назад.
+func MaxAge(age time.Duration) FilterFunc {
+	return func(fileInfo os.FileInfo) bool {
+		return time.Since(fileInfo.ModTime()) <= age
+	}
+}
+
+//IncludeExclude возвращает фильтр, который пропускает элементы, соответствующие хотя бы одному
+//из шаблонов include (если они заданы) и не соответствующие ни одному из шаблонов exclude (см. Pattern).
+func IncludeExclude(include, exclude []string) (FilterFunc, error) {
+	filters := []FilterFunc{defaultFilterFunc}
+
+	if len(include) > 0 {
+		includeFilters, err := patterns(include)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, Or(includeFilters...))
+	}
+	if len(exclude) > 0 {
+		excludeFilters, err := patterns(exclude)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, Not(Or(excludeFilters...)))
+	}
+
+	return And(filters...), nil
+}
+
+func patterns(list []string) (res []FilterFunc, err error) {
+	for _, pattern := range list {
+		filter, err := Pattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, filter)
+	}
+
+	return
+}
@@ -71,3 +71,15 @@ func isFileLocked(pathName string) bool {
 
 	return false
 }
+
+//syncDir сбрасывает на диск содержимое каталога, чтобы переименование файла пережило сбой питания.
+//Не все платформы позволяют открыть каталог для этого, поэтому ошибки игнорируются.
+func syncDir(path string) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer dir.Close()
+
+	_ = dir.Sync()
+}
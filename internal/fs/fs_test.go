@@ -1,9 +1,15 @@
 package fs
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -88,3 +94,601 @@ func Test_isFileLocked(t *testing.T) {
 
 	assert.False(t, isFileLocked(file.Name()))
 }
+
+func TestDirReader_ReadRecursive(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, dir := range []string{"a/b", ".hidden"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"1.txt", "a/2.txt", "a/b/3.txt", ".hidden/4.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	regular := func(fileInfo os.FileInfo) bool { return fileInfo.Mode().IsRegular() }
+	relPaths := func(files []*File) (res []string) {
+		for _, file := range files {
+			res = append(res, filepath.ToSlash(file.RelativePath()))
+		}
+		return
+	}
+
+	files, err := NewDirReaderWithFilter(root, regular).Read()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"1.txt"}, relPaths(files))
+
+	files, err = NewRecursiveDirReader(root, regular, WalkOptions{}).Read()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"1.txt", "a/2.txt", "a/b/3.txt", ".hidden/4.txt"}, relPaths(files))
+
+	files, err = NewRecursiveDirReader(root, regular, WalkOptions{MaxDepth: 2, SkipHidden: true}).Read()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"1.txt", "a/2.txt"}, relPaths(files))
+}
+
+func Test_stabilityGate(t *testing.T) {
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	files := []*File{{PathName: file.Name()}}
+	gate := newStabilityGate(StabilityOptions{Polls: 2})
+
+	assert.Empty(t, gate.filter(files))
+	assert.Empty(t, gate.filter(files))
+
+	file.WriteString("data")
+	assert.Empty(t, gate.filter(files))
+	assert.Empty(t, gate.filter(files))
+	assert.Len(t, gate.filter(files), 1)
+}
+
+func TestDiff(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, name := range []string{"modified", "removed", "renamed"} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader := NewDirReader(root)
+	prev, err := reader.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "modified"), []byte("data"), 0644))
+	assert.Nil(t, os.Remove(filepath.Join(root, "removed")))
+	assert.Nil(t, os.Rename(filepath.Join(root, "renamed"), filepath.Join(root, "renamed_new")))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "created"), []byte("new"), 0644))
+
+	curr, err := reader.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := Diff(prev, curr)
+	if assert.Len(t, events, 4) {
+		assert.Equal(t, Created, events[0].Type)
+		assert.Equal(t, "created", events[0].File.Name())
+		assert.Equal(t, Modified, events[1].Type)
+		assert.Equal(t, Removed, events[2].Type)
+		assert.Nil(t, events[2].NewInfo)
+		assert.Equal(t, Renamed, events[3].Type)
+		assert.Equal(t, "renamed", events[3].OldFile.Name())
+		assert.Equal(t, "renamed_new", events[3].File.Name())
+	}
+}
+
+func Test_eventQueue(t *testing.T) {
+	batch := func(names ...string) (res []*File) {
+		for _, name := range names {
+			res = append(res, &File{PathName: name})
+		}
+		return
+	}
+
+	queue := newEventQueue(DeliverDropOldest, 2)
+	queue.push(batch("1"))
+	queue.push(batch("2"))
+	queue.push(batch("3"))
+	next, ok := queue.front()
+	assert.True(t, ok)
+	assert.Equal(t, "2", next[0].Name())
+	queue.pop()
+	assert.Equal(t, DeliveryStats{Delivered: 1, Dropped: 1}, queue.stats())
+
+	queue = newEventQueue(DeliverBlock, 1)
+	assert.False(t, queue.blocked())
+	queue.push(batch("1"))
+	assert.True(t, queue.blocked())
+
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	queue = newEventQueue(DeliverCoalesce, 1)
+	queue.push(batch(file.Name(), file.Name()+"_non_existent"))
+	queue.push(batch("new"))
+	next, _ = queue.front()
+	assert.Len(t, next, 2)
+	assert.Equal(t, DeliveryStats{Coalesced: 1}, queue.stats())
+}
+
+func Test_retrier(t *testing.T) {
+	now := time.Now()
+	r := newRetrier(RetryPolicy{InitialDelay: time.Second, MaxDelay: 3 * time.Second, MaxElapsed: 10 * time.Second})
+	r.now = func() time.Time { return now }
+
+	_, ok := r.next(os.ErrPermission)
+	assert.False(t, ok)
+
+	var delays []time.Duration
+	for {
+		delay, ok := r.next(ErrNotExists)
+		if !ok {
+			break
+		}
+		delays = append(delays, delay)
+		now = now.Add(delay)
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}, delays)
+
+	r.reset()
+	delay, ok := r.next(ErrNotExists)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, delay)
+}
+
+func TestFile_MoveTo(t *testing.T) {
+	srcPathName, dstDirName, cleanup := copyFixture(t, []byte("data"))
+	defer cleanup()
+
+	file := &File{PathName: srcPathName}
+	strategy, err := file.MoveTo(dstDirName)
+	assert.Nil(t, err)
+	assert.Equal(t, MoveRenamed, strategy)
+	assert.Equal(t, filepath.Join(dstDirName, "file"), file.AbsolutePath())
+	assert.False(t, isExists(srcPathName))
+
+	if err := ioutil.WriteFile(srcPathName, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = (&File{PathName: srcPathName}).MoveTo(dstDirName)
+	assert.True(t, errors.Is(err, ErrAlreadyExists))
+}
+
+func TestFile_CopyTo(t *testing.T) {
+	srcPathName, dstDirName, cleanup := copyFixture(t, []byte("data"))
+	defer cleanup()
+	leftover := filepath.Join(dstDirName, ".old"+PartialSuffix)
+	if err := ioutil.WriteFile(leftover, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dstFile, err := (&File{PathName: srcPathName}).CopyTo(dstDirName)
+	assert.Nil(t, err)
+	data, err := ioutil.ReadFile(dstFile.AbsolutePath())
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.Equal(t, []string{".old" + PartialSuffix}, partialFiles(t, dstDirName))
+
+	removed, err := RemovePartialFiles(dstDirName, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{leftover}, removed)
+	assert.True(t, isExists(dstFile.AbsolutePath()))
+}
+
+//copyFixture создаёт во временном каталоге файл "file" с содержимым data и пустой каталог назначения "dst".
+//Возвращает пути к ним и функцию удаления временного каталога.
+func copyFixture(t *testing.T, data []byte) (srcPathName, dstDirName string, cleanup func()) {
+	dirName, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup = func() { os.RemoveAll(dirName) }
+
+	srcPathName = filepath.Join(dirName, "file")
+	if err := ioutil.WriteFile(srcPathName, data, 0644); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	dstDirName = filepath.Join(dirName, "dst")
+	if err := os.Mkdir(dstDirName, 0755); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	return
+}
+
+//partialFiles возвращает имена временных файлов копирования в каталоге.
+func partialFiles(t *testing.T, dirName string) (names []string) {
+	files, err := NewDirReaderWithFilter(dirName, func(fileInfo os.FileInfo) bool {
+		return IsPartialFile(fileInfo.Name())
+	}).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+
+	return
+}
+
+func TestFile_CopyToConcurrent(t *testing.T) {
+	dirName, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirName)
+
+	dstDirName := filepath.Join(dirName, "dst")
+	if err := os.Mkdir(dstDirName, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	//файлы с одинаковыми именами из разных папок копируются одновременно
+	const count = 8
+	var wg sync.WaitGroup
+	errs := make([]error, count)
+	for i := 0; i < count; i++ {
+		srcDirName := filepath.Join(dirName, strconv.Itoa(i))
+		if err := os.Mkdir(srcDirName, 0755); err != nil {
+			t.Fatal(err)
+		}
+		srcPathName := filepath.Join(srcDirName, "file.txt")
+		if err := ioutil.WriteFile(srcPathName, []byte(strconv.Itoa(i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = (&File{PathName: srcPathName}).CopyTo(dstDirName, WithConflictPolicy(ConflictRename))
+		}(i)
+	}
+	wg.Wait()
+
+	var contents []string
+	files, err := NewDirReader(dstDirName).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file.AbsolutePath())
+		assert.Nil(t, err)
+		contents = append(contents, string(data))
+	}
+	for i := 0; i < count; i++ {
+		assert.Nil(t, errs[i])
+		assert.Contains(t, contents, strconv.Itoa(i))
+	}
+	assert.Len(t, files, count)
+}
+
+func Test_placementPlace(t *testing.T) {
+	dirName, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirName)
+
+	src := &File{PathName: filepath.Join(dirName, "src.txt")}
+	dstPathName := filepath.Join(dirName, "dst.txt")
+	write := func(pathName, data string) {
+		if err := ioutil.WriteFile(pathName, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(src.AbsolutePath(), "new data")
+
+	//файл с итоговым именем появляется после разрешения конфликта
+	for _, policy := range []ConflictPolicy{ConflictRename, ConflictFail} {
+		os.Remove(dstPathName)
+		p, err := resolvePlacement(src, dstPathName, policy)
+		assert.Nil(t, err)
+		assert.Equal(t, dstPathName, p.pathName)
+
+		tmpPathName := filepath.Join(dirName, "tmp")
+		write(tmpPathName, "new data")
+		write(dstPathName, "data")
+		err = p.place(tmpPathName)
+
+		existing, _ := ioutil.ReadFile(dstPathName)
+		assert.Equal(t, "data", string(existing))
+		if policy == ConflictRename {
+			assert.Nil(t, err)
+			assert.Equal(t, filepath.Join(dirName, "dst (1).txt"), p.pathName)
+			assert.False(t, isExists(tmpPathName))
+		} else {
+			assert.True(t, errors.Is(err, ErrAlreadyExists))
+			assert.True(t, isExists(tmpPathName))
+		}
+	}
+}
+
+func Test_verifyChecksum(t *testing.T) {
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("data")
+	file.Close()
+
+	for _, alg := range []HashAlgorithm{HashSHA256, HashCRC32, HashXXHash} {
+		hasher := alg.new()
+		hasher.Write([]byte("data"))
+		assert.Nil(t, verifyChecksum(file.Name(), alg, hasher.Sum(nil)))
+
+		hasher.Write([]byte("more data"))
+		assert.True(t, errors.Is(verifyChecksum(file.Name(), alg, hasher.Sum(nil)), ErrChecksumMismatch))
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	dirName, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirName)
+
+	src := &File{PathName: filepath.Join(dirName, "src.txt")}
+	if err := ioutil.WriteFile(src.AbsolutePath(), []byte("new data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dstPathName := filepath.Join(dirName, "dst.txt")
+	for _, name := range []string{"dst.txt", "dst (1).txt"} {
+		if err := ioutil.WriteFile(filepath.Join(dirName, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pathName, overwrite, err := ResolveConflict(src, filepath.Join(dirName, "free.txt"), ConflictFail)
+	assert.Nil(t, err)
+	assert.False(t, overwrite)
+	assert.Equal(t, filepath.Join(dirName, "free.txt"), pathName)
+
+	_, _, err = ResolveConflict(src, dstPathName, ConflictFail)
+	assert.True(t, errors.Is(err, ErrAlreadyExists))
+
+	_, _, err = ResolveConflict(src, dstPathName, ConflictSkip)
+	assert.True(t, errors.Is(err, ErrSkipped))
+
+	pathName, _, err = ResolveConflict(src, dstPathName, ConflictRename)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dirName, "dst (2).txt"), pathName)
+
+	pathName, overwrite, err = ResolveConflict(src, dstPathName, ConflictKeepLarger)
+	assert.Nil(t, err)
+	assert.True(t, overwrite)
+	assert.Equal(t, dstPathName, pathName)
+
+	past := time.Now().Add(-time.Hour)
+	assert.Nil(t, os.Chtimes(src.AbsolutePath(), past, past))
+	_, _, err = ResolveConflict(src, dstPathName, ConflictKeepNewer)
+	assert.True(t, errors.Is(err, ErrSkipped))
+}
+
+func TestIncludeExclude(t *testing.T) {
+	dirName, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirName)
+
+	for _, name := range []string{"video.mp4", "video.MKV", "notes.txt", ".video.mp4.swp"} {
+		if err := ioutil.WriteFile(filepath.Join(dirName, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(filter FilterFunc) (res []string) {
+		files, err := NewDirReaderWithFilter(dirName, filter).Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range files {
+			res = append(res, file.Name())
+		}
+		return
+	}
+
+	filter, err := IncludeExclude([]string{"*.mp4", `re:(?i)\.mkv$`}, []string{".*"})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"video.mp4", "video.MKV"}, names(filter))
+
+	assert.ElementsMatch(t, []string{"video.mp4", "video.MKV", ".video.mp4.swp"}, names(Or(Extensions("mkv", ".swp"), must(Glob("*.mp4")))))
+	assert.ElementsMatch(t, []string{"notes.txt"}, names(And(Not(Extensions("mp4", "mkv", "swp")), MinSize(4), MaxSize(4))))
+	assert.Empty(t, names(MinSize(5)))
+
+	_, err = IncludeExclude([]string{"["}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestParseSize(t *testing.T) {
+	for s, expected := range map[string]int64{"512": 512, "64K": 64 << 10, "10mb": 10 << 20, "1.5G": 3 << 29} {
+		size, err := ParseSize(s)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, size)
+	}
+
+	_, err := ParseSize("ten")
+	assert.NotNil(t, err)
+}
+
+func must(filter FilterFunc, err error) FilterFunc {
+	if err != nil {
+		panic(err)
+	}
+	return filter
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	var slept time.Duration
+
+	rule, err := ParseRateRule("08:00-18:00=1K")
+	assert.Nil(t, err)
+	limiter := NewScheduledRateLimiter(RateSchedule{Rules: []RateRule{rule}})
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		now = now.Add(d)
+		return nil
+	}
+
+	ctx := context.Background()
+	assert.Nil(t, limiter.wait(ctx, 1024))
+	assert.Nil(t, limiter.wait(ctx, 2048))
+	assert.Equal(t, 3*time.Second, slept)
+
+	//ночью скорость не ограничена
+	now = time.Date(2020, 1, 1, 23, 0, 0, 0, time.Local)
+	slept = 0
+	assert.Nil(t, limiter.wait(ctx, 1<<30))
+	assert.Zero(t, slept)
+
+	rule, err = ParseRateRule("22:00-06:00=unlimited")
+	assert.Nil(t, err)
+	assert.True(t, rule.contains(23*time.Hour))
+	assert.True(t, rule.contains(time.Hour))
+	assert.False(t, rule.contains(12*time.Hour))
+
+	_, err = ParseRateRule("08:00=1K")
+	assert.NotNil(t, err)
+}
+
+func TestFile_CopyToWithProgress(t *testing.T) {
+	srcPathName, dstDirName, cleanup := copyFixture(t, make([]byte, 3*progressChunk+1))
+	defer cleanup()
+
+	var reports []Progress
+	_, err := (&File{PathName: srcPathName}).CopyTo(dstDirName, WithProgress(0, func(progress Progress) {
+		reports = append(reports, progress)
+	}))
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, reports) {
+		last := reports[len(reports)-1]
+		assert.True(t, last.Done())
+		assert.Equal(t, int64(3*progressChunk+1), last.Total)
+		assert.Zero(t, last.ETA)
+	}
+}
+
+func TestFile_MoveToContextCanceled(t *testing.T) {
+	srcPathName, dstDirName, cleanup := copyFixture(t, make([]byte, 3*progressChunk))
+	defer cleanup()
+
+	//отменяем копирование после первого сообщения о ходе копирования
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := (&File{PathName: srcPathName}).CopyToContext(ctx, dstDirName, WithProgress(0, func(Progress) { cancel() }))
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, isExists(srcPathName))
+	assert.False(t, isExists(filepath.Join(dstDirName, "file")))
+	assert.Empty(t, partialFiles(t, dstDirName))
+
+	_, err = (&File{PathName: srcPathName}).MoveToContext(ctx, dstDirName)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, isExists(srcPathName))
+}
+
+func TestFile_CopyToWithPreserve(t *testing.T) {
+	srcPathName, dstDirName, cleanup := copyFixture(t, []byte("data"))
+	defer cleanup()
+
+	if err := os.Chmod(srcPathName, 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	if err := os.Chtimes(srcPathName, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	attrs, err := ParseAttributes("mode, times")
+	assert.Nil(t, err)
+	assert.Equal(t, AttrMode|AttrTimes, attrs)
+	_, err = ParseAttributes("acl")
+	assert.NotNil(t, err)
+
+	dstFile, err := (&File{PathName: srcPathName}).CopyTo(dstDirName, WithPreserve(attrs))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	stat, err := os.Stat(dstFile.AbsolutePath())
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), stat.Mode().Perm())
+	assert.True(t, mtime.Equal(stat.ModTime()))
+}
+
+func TestPathTemplate(t *testing.T) {
+	dirName, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirName)
+
+	if err := os.Mkdir(filepath.Join(dirName, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pathName := filepath.Join(dirName, "sub", "report.pdf")
+	if err := ioutil.WriteFile(pathName, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 3, 4, 5, 6, 7, 0, time.Local)
+	if err := os.Chtimes(pathName, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	file := &File{PathName: pathName, root: dirName}
+	now := time.Date(2021, 12, 31, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"{mtime:2006}/{mtime:01}/{name}", filepath.Join("2020", "03", "report.pdf")},
+		{"/archive/{now:2006-01}/{base}_{mtime}{ext}", filepath.Join(string(filepath.Separator), "archive", "2021-12", "report_2020-03-04.pdf")},
+		{"{dir}/{name}", filepath.Join("sub", "report.pdf")},
+	}
+	for _, test := range tests {
+		template, err := ParsePathTemplate(test.template)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		actual, err := template.expand(file, now)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, actual)
+	}
+
+	for _, text := range []string{"", "{mtime:2006", "{size}", "{name:2006}"} {
+		_, err := ParsePathTemplate(text)
+		assert.NotNil(t, err, text)
+	}
+	for _, text := range []string{"{mtime:2006}/", "archive/.."} {
+		template, err := ParsePathTemplate(text)
+		assert.Nil(t, err)
+		_, err = template.expand(file, now)
+		assert.NotNil(t, err, text)
+	}
+}
@@ -0,0 +1,120 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM |
+	syscall.IN_DELETE | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF | syscall.IN_ATTRIB
+
+//inotifyBackend сигнализирует о необходимости перечитать каталог по событиям inotify. Дополнительно
+//каталог перечитывается с интервалом resyncInterval: это позволяет не потерять события при переполнении
+//очереди inotify и повторно обработать файлы, которые не удалось обработать ранее.
+type inotifyBackend struct {
+	dirReader      DirReader
+	resyncInterval time.Duration
+	fd             int
+	//file закрывается по окончании Run, поэтому Run можно вызвать только один раз
+	file *os.File
+}
+
+//NewInotifyBackend возвращает бэкенд, использующий подсистему inotify. Если экземпляр inotify не удалось
+//создать (например, исчерпан лимит fs.inotify.max_user_instances), то возвращается ошибка. Дескриптор
+//бэкенда, который так и не был запущен, закрывается сборщиком мусора.
+func NewInotifyBackend(dirReader DirReader, resyncInterval time.Duration) (Backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	return &inotifyBackend{
+		dirReader:      dirReader,
+		resyncInterval: resyncInterval,
+		fd:             fd,
+		//неблокирующий дескриптор обслуживается планировщиком Go, поэтому Close прерывает ожидающий Read
+		file: os.NewFile(uintptr(fd), "inotify"),
+	}, nil
+}
+
+func (b *inotifyBackend) Run(ctx context.Context, notify chan<- struct{}) error {
+	fd, file := b.fd, b.file
+
+	events := make(chan uint32)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		readErr <- readInotifyEvents(file, events, done)
+	}()
+	defer func() {
+		close(done)
+		file.Close()
+	}()
+
+	b.addWatches(fd)
+	signal(notify)
+
+	ticker := time.NewTicker(b.resyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			return err
+		case mask := <-events:
+			if mask&(syscall.IN_ISDIR|syscall.IN_Q_OVERFLOW) != 0 {
+				b.addWatches(fd)
+			}
+			signal(notify)
+		case <-ticker.C:
+			b.addWatches(fd)
+			signal(notify)
+		}
+	}
+}
+
+//addWatches добавляет отслеживаемый каталог (и его подкаталоги при рекурсивном обходе) в inotify.
+//Повторное добавление уже отслеживаемого каталога безопасно. Ошибки игнорируются: если каталог
+//недоступен, то об этом сообщит DirReader при чтении.
+func (b *inotifyBackend) addWatches(fd int) {
+	dirs, err := b.dirReader.dirs()
+	if err != nil {
+		return
+	}
+
+	for _, dir := range dirs {
+		syscall.InotifyAddWatch(fd, dir, inotifyMask)
+	}
+}
+
+//readInotifyEvents читает события из дескриптора inotify и передаёт их маски в канал events до тех пор,
+//пока не будет закрыт канал done (или дескриптор).
+func readInotifyEvents(file *os.File, events chan<- uint32, done <-chan struct{}) error {
+	var buf [syscall.SizeofInotifyEvent * 4096]byte
+
+	for {
+		n, err := file.Read(buf[:])
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return os.NewSyscallError("inotify read", err)
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			select {
+			case events <- event.Mask:
+			case <-done:
+				return nil
+			}
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+		}
+	}
+}
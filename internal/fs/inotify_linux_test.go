@@ -0,0 +1,50 @@
+package fs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBackend_inotify(t *testing.T) {
+	dirName, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirName)
+
+	backend, err := NewBackend(WatchModeAuto, NewDirReader(dirName), time.Hour)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.IsType(t, &inotifyBackend{}, backend)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	notify := make(chan struct{}, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- backend.Run(ctx, notify)
+	}()
+
+	//первый сигнал отправляется сразу после запуска, следующий - по событию
+	for _, name := range []string{"", "file"} {
+		if name != "" {
+			if err := ioutil.WriteFile(filepath.Join(dirName, name), []byte("data"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		select {
+		case <-notify:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no signal from the inotify backend")
+		}
+	}
+
+	cancel()
+	assert.Nil(t, <-done)
+}
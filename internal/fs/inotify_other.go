@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package fs
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+//NewInotifyBackend возвращает ошибку: inotify поддерживается только в Linux.
+func NewInotifyBackend(dirReader DirReader, resyncInterval time.Duration) (Backend, error) {
+	return nil, fmt.Errorf("inotify is not supported on %s: %w", runtime.GOOS, ErrNotSupported)
+}
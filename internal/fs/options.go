@@ -0,0 +1,89 @@
+package fs
+
+import (
+	"path/filepath"
+	"time"
+)
+
+//CopyOption дополнительная настройка копирования (перемещения) файла.
+type CopyOption func(o *copyOptions)
+
+type copyOptions struct {
+	verify   HashAlgorithm
+	conflict ConflictPolicy
+	limiter  *RateLimiter
+
+	progressInterval time.Duration
+	progress         ProgressFunc
+
+	preserve Attributes
+
+	name string
+}
+
+func newCopyOptions(opts []CopyOption) copyOptions {
+	o := copyOptions{preserve: AttrTimes}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+//WithVerify включает проверку скопированного файла: контрольная сумма вычисляется при копировании
+//и сравнивается с контрольной суммой, заново прочитанной из нового файла. При перемещении исходный
+//файл удаляется только после успешной проверки.
+func WithVerify(alg HashAlgorithm) CopyOption {
+	return func(o *copyOptions) {
+		o.verify = alg
+	}
+}
+
+//WithConflictPolicy задаёт способ разрешения конфликта, когда в новом расположении уже есть файл
+//с таким же именем (по умолчанию ConflictFail).
+func WithConflictPolicy(policy ConflictPolicy) CopyOption {
+	return func(o *copyOptions) {
+		o.conflict = policy
+	}
+}
+
+//WithRateLimit ограничивает скорость копирования. Один ограничитель можно использовать в нескольких
+//одновременных операциях, тогда ограничивается их суммарная скорость.
+func WithRateLimit(limiter *RateLimiter) CopyOption {
+	return func(o *copyOptions) {
+		o.limiter = limiter
+	}
+}
+
+//WithProgress включает сообщения о ходе копирования: функция fn вызывается не чаще, чем раз в interval,
+//а также по окончании копирования. При перемещении переименованием функция не вызывается.
+func WithProgress(interval time.Duration, fn ProgressFunc) CopyOption {
+	return func(o *copyOptions) {
+		o.progressInterval = interval
+		o.progress = fn
+	}
+}
+
+//WithPreserve задаёт атрибуты исходного файла, которые переносятся на копию (по умолчанию AttrTimes).
+//При перемещении переименованием файл сохраняет все свои атрибуты.
+func WithPreserve(attrs Attributes) CopyOption {
+	return func(o *copyOptions) {
+		o.preserve = attrs
+	}
+}
+
+//WithTargetName задаёт имя файла в новом расположении (по умолчанию - имя исходного файла).
+func WithTargetName(name string) CopyOption {
+	return func(o *copyOptions) {
+		o.name = name
+	}
+}
+
+//targetPathName возвращает путь к файлу f в каталоге path.
+func (o copyOptions) targetPathName(f *File, path string) string {
+	if o.name != "" {
+		return filepath.Join(path, o.name)
+	}
+
+	return filepath.Join(path, f.Name())
+}
@@ -0,0 +1,59 @@
+package fs
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//PartialSuffix суффикс временных файлов, в которые выполняется копирование. Имя временного файла
+//образуется из имени итогового файла, к которому спереди добавляется точка, а в конце - случайное
+//число и этот суффикс: ".name.ext.123456.partial".
+const PartialSuffix = ".partial"
+
+//IsPartialFile возвращает true, если файл с таким именем является временным файлом копирования.
+func IsPartialFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, PartialSuffix) && len(name) > len(PartialSuffix)+1
+}
+
+//createPartialFile создаёт временный файл копирования для итогового файла pathName. Имя временного
+//файла уникально, поэтому одновременные копии под одним итоговым именем не пишут в один файл.
+func createPartialFile(pathName string) (*os.File, error) {
+	prefix := filepath.Join(filepath.Dir(pathName), "."+filepath.Base(pathName))
+	for {
+		tmpPathName := fmt.Sprintf("%s.%d%s", prefix, rand.Uint32(), PartialSuffix)
+		file, err := os.OpenFile(tmpPathName, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if !os.IsExist(err) {
+			return file, err
+		}
+	}
+}
+
+//RemovePartialFiles удаляет временные файлы копирования, оставшиеся в каталоге после аварийного
+//завершения программы. Возвращает список удалённых файлов.
+func RemovePartialFiles(path string, recursive bool) (removed []string, err error) {
+	filter := func(fileInfo os.FileInfo) bool {
+		return fileInfo.Mode().IsRegular() && IsPartialFile(fileInfo.Name())
+	}
+
+	dirReader := NewDirReaderWithFilter(path, filter)
+	if recursive {
+		dirReader = NewRecursiveDirReader(path, filter, WalkOptions{SkipSymlinks: true})
+	}
+
+	files, err := dirReader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if err = file.Delete(); err != nil {
+			return
+		}
+		removed = append(removed, file.AbsolutePath())
+	}
+
+	return
+}
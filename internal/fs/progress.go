@@ -0,0 +1,101 @@
+package fs
+
+import (
+	"io"
+	"time"
+)
+
+//progressChunk максимальный объём данных, записываемый между проверками необходимости сообщить о ходе копирования.
+const progressChunk = 1 << 20 //1Mb
+
+//Progress состояние копирования файла.
+type Progress struct {
+	//File копируемый файл
+	File *File
+	//Copied количество скопированных байт
+	Copied int64
+	//Total размер файла в байтах
+	Total int64
+	//Elapsed время, прошедшее с начала копирования
+	Elapsed time.Duration
+	//Throughput средняя скорость копирования в байтах в секунду
+	Throughput float64
+	//ETA оценка времени, оставшегося до окончания копирования
+	ETA time.Duration
+}
+
+//Done возвращает true, если копирование завершено.
+func (p Progress) Done() bool {
+	return p.Copied >= p.Total
+}
+
+//ProgressFunc получает сведения о ходе копирования файла.
+type ProgressFunc func(progress Progress)
+
+//progressWriter подсчитывает записанные данные и не чаще, чем раз в interval, сообщает о ходе копирования.
+type progressWriter struct {
+	w        io.Writer
+	fn       ProgressFunc
+	interval time.Duration
+	progress Progress
+	start    time.Time
+	reported time.Time
+	now      func() time.Time
+}
+
+func newProgressWriter(w io.Writer, file *File, total int64, interval time.Duration, fn ProgressFunc) *progressWriter {
+	now := time.Now()
+
+	return &progressWriter{
+		w:        w,
+		fn:       fn,
+		interval: interval,
+		progress: Progress{File: file, Total: total},
+		start:    now,
+		reported: now,
+		now:      time.Now,
+	}
+}
+
+func (w *progressWriter) Write(p []byte) (written int, err error) {
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > progressChunk {
+			chunk = chunk[:progressChunk]
+		}
+
+		n, err := w.w.Write(chunk)
+		written += n
+		w.progress.Copied += int64(n)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+
+		if now := w.now(); now.Sub(w.reported) >= w.interval {
+			w.report(now)
+		}
+	}
+
+	return
+}
+
+//finish сообщает об окончании копирования.
+func (w *progressWriter) finish() {
+	w.report(w.now())
+}
+
+func (w *progressWriter) report(now time.Time) {
+	w.reported = now
+	w.progress.Elapsed = now.Sub(w.start)
+
+	w.progress.Throughput, w.progress.ETA = 0, 0
+	if seconds := w.progress.Elapsed.Seconds(); seconds > 0 {
+		w.progress.Throughput = float64(w.progress.Copied) / seconds
+	}
+	if remaining := w.progress.Total - w.progress.Copied; remaining > 0 && w.progress.Throughput > 0 {
+		w.progress.ETA = time.Duration(float64(remaining) / w.progress.Throughput * float64(time.Second))
+	}
+
+	w.fn(w.progress)
+}
@@ -0,0 +1,190 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+//rateLimitChunk максимальный объём данных, записываемый за одно ожидание ограничителя скорости.
+const rateLimitChunk = 256 << 10 //256Kb
+
+//RateLimiter ограничивает суммарную скорость копирования всех операций, которые его используют.
+type RateLimiter struct {
+	mu       sync.Mutex
+	schedule RateSchedule
+	tokens   float64
+	last     time.Time
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+//NewRateLimiter возвращает ограничитель с постоянной скоростью (байт в секунду, 0 - без ограничений).
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	return NewScheduledRateLimiter(RateSchedule{Default: bytesPerSecond})
+}
+
+//NewScheduledRateLimiter возвращает ограничитель, скорость которого зависит от времени суток.
+func NewScheduledRateLimiter(schedule RateSchedule) *RateLimiter {
+	return &RateLimiter{
+		schedule: schedule,
+		now:      time.Now,
+		sleep:    sleep,
+	}
+}
+
+//wait ожидает, пока не станет можно передать n байт. Ограничитель допускает всплески объёмом
+//не более чем за одну секунду передачи; превышение этого объёма компенсируется ожиданием.
+//Ожидание прерывается при отмене ctx.
+func (l *RateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := l.now()
+	rate := float64(l.schedule.rate(now))
+	if rate <= 0 {
+		l.last = now
+		l.mu.Unlock()
+		return nil
+	}
+
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * rate
+	}
+	if l.tokens > rate {
+		l.tokens = rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		return l.sleep(ctx, delay)
+	}
+
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//rateLimitedWriter записывает данные не быстрее, чем позволяет ограничитель.
+type rateLimitedWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *RateLimiter
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (written int, err error) {
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > rateLimitChunk {
+			chunk = chunk[:rateLimitChunk]
+		}
+
+		if err := w.limiter.wait(w.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+
+	return
+}
+
+//RateRule ограничение скорости, действующее в интервале времени суток [Start, End). Если End меньше Start,
+//то интервал переходит через полночь.
+type RateRule struct {
+	Start, End     time.Duration
+	BytesPerSecond int64
+}
+
+func (r RateRule) contains(timeOfDay time.Duration) bool {
+	if r.Start <= r.End {
+		return timeOfDay >= r.Start && timeOfDay < r.End
+	}
+
+	return timeOfDay >= r.Start || timeOfDay < r.End
+}
+
+//RateSchedule расписание ограничения скорости: действует первое подходящее правило, а вне правил - Default
+//(байт в секунду, 0 - без ограничений).
+type RateSchedule struct {
+	Default int64
+	Rules   []RateRule
+}
+
+func (s RateSchedule) rate(now time.Time) int64 {
+	timeOfDay := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute + time.Duration(now.Second())*time.Second
+	for _, rule := range s.Rules {
+		if rule.contains(timeOfDay) {
+			return rule.BytesPerSecond
+		}
+	}
+
+	return s.Default
+}
+
+//ParseRateRule разбирает правило расписания вида "08:00-18:00=10M", где после знака равенства указывается
+//скорость в байтах в секунду (см. ParseSize) или "unlimited".
+func ParseRateRule(s string) (RateRule, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return RateRule{}, fmt.Errorf("invalid rate rule '%s'", s)
+	}
+	interval := strings.SplitN(parts[0], "-", 2)
+	if len(interval) != 2 {
+		return RateRule{}, fmt.Errorf("invalid time interval in the rate rule '%s'", s)
+	}
+
+	var rule RateRule
+	var err error
+	if rule.Start, err = parseTimeOfDay(interval[0]); err != nil {
+		return RateRule{}, fmt.Errorf("invalid rate rule '%s': %w", s, err)
+	}
+	if rule.End, err = parseTimeOfDay(interval[1]); err != nil {
+		return RateRule{}, fmt.Errorf("invalid rate rule '%s': %w", s, err)
+	}
+	if rule.BytesPerSecond, err = ParseRate(parts[1]); err != nil {
+		return RateRule{}, fmt.Errorf("invalid rate rule '%s': %w", s, err)
+	}
+
+	return rule, nil
+}
+
+//ParseRate разбирает скорость в байтах в секунду (см. ParseSize). Значение "unlimited" означает отсутствие ограничений (0).
+func ParseRate(s string) (int64, error) {
+	if s = strings.TrimSpace(s); s == "unlimited" {
+		return 0, nil
+	}
+
+	return ParseSize(s)
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day '%s'", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,84 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+//RetryPolicy параметры повторных попыток чтения каталога после временных ошибок.
+type RetryPolicy struct {
+	//InitialDelay задержка перед первой повторной попыткой (по умолчанию 1 секунда)
+	InitialDelay time.Duration
+	//MaxDelay максимальная задержка между попытками (по умолчанию 1 минута)
+	MaxDelay time.Duration
+	//MaxElapsed время, в течение которого делаются повторные попытки (0 - без ограничений)
+	MaxElapsed time.Duration
+	//IsTransient определяет, является ли ошибка временной (по умолчанию IsTransientError)
+	IsTransient func(err error) bool
+}
+
+//IsTransientError возвращает false для ошибок, которые не исчезнут сами по себе (отсутствие прав доступа,
+//отслеживаемый путь не является каталогом). Остальные ошибки, в том числе отсутствие каталога
+//(например, при отключении сетевого ресурса), считаются временными.
+func IsTransientError(err error) bool {
+	if os.IsPermission(err) || errors.Is(err, ErrNotDirectory) {
+		return false
+	}
+
+	return true
+}
+
+//retrier хранит состояние серии повторных попыток.
+type retrier struct {
+	policy       RetryPolicy
+	attempt      int
+	delay        time.Duration
+	firstFailure time.Time
+	now          func() time.Time
+}
+
+func newRetrier(policy RetryPolicy) *retrier {
+	if policy.InitialDelay <= 0 {
+		policy.InitialDelay = 1 * time.Second
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = 1 * time.Minute
+	}
+	if policy.IsTransient == nil {
+		policy.IsTransient = IsTransientError
+	}
+
+	return &retrier{
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+//next возвращает задержку перед следующей попыткой или false, если повторять попытки не нужно.
+func (r *retrier) next(err error) (time.Duration, bool) {
+	if !r.policy.IsTransient(err) {
+		return 0, false
+	}
+
+	now := r.now()
+	if r.attempt == 0 {
+		r.firstFailure = now
+		r.delay = r.policy.InitialDelay
+	} else {
+		r.delay *= 2
+		if r.delay > r.policy.MaxDelay {
+			r.delay = r.policy.MaxDelay
+		}
+	}
+	if r.policy.MaxElapsed > 0 && now.Add(r.delay).Sub(r.firstFailure) > r.policy.MaxElapsed {
+		return 0, false
+	}
+	r.attempt++
+
+	return r.delay, true
+}
+
+func (r *retrier) reset() {
+	r.attempt = 0
+}
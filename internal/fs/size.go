@@ -0,0 +1,47 @@
+package fs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+//ParseSize разбирает размер в байтах, заданный числом с необязательной единицей измерения
+//(B, K/KB, M/MB, G/GB, T/TB без учёта регистра, множитель 1024), например "512", "64K", "1.5GB".
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.multiplier
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+
+	return int64(number * float64(multiplier)), nil
+}
+
+//FormatSize возвращает размер в байтах в удобочитаемом виде, например "1.5 GB".
+func FormatSize(size int64) string {
+	for _, unit := range sizeUnits[:4] {
+		if size >= unit.multiplier {
+			return fmt.Sprintf("%.1f %s", float64(size)/float64(unit.multiplier), unit.suffix)
+		}
+	}
+
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,90 @@
+package fs
+
+import (
+	"os"
+	"time"
+)
+
+//StabilityOptions условия, при которых файл считается стабильным (запись в него завершена).
+//Файл считается стабильным, если его размер и время модификации не менялись в течение Polls
+//последовательных опросов каталога или в течение QuietPeriod. Нулевое значение условия отключает его.
+//Polls имеет смысл только при периодическом опросе каталога: при отслеживании через inotify каталог
+//перечитывается после каждого события, и опросы быстро следуют друг за другом.
+type StabilityOptions struct {
+	Polls       int
+	QuietPeriod time.Duration
+}
+
+func (o StabilityOptions) enabled() bool {
+	return o.Polls > 0 || o.QuietPeriod > 0
+}
+
+type fileState struct {
+	size      int64
+	modTime   time.Time
+	unchanged int
+	since     time.Time
+}
+
+//stabilityGate пропускает только те файлы, которые перестали изменяться.
+type stabilityGate struct {
+	opts   StabilityOptions
+	states map[string]*fileState
+	now    func() time.Time
+}
+
+func newStabilityGate(opts StabilityOptions) *stabilityGate {
+	return &stabilityGate{
+		opts:   opts,
+		states: make(map[string]*fileState),
+		now:    time.Now,
+	}
+}
+
+//filter возвращает стабильные файлы из очередного среза содержимого каталога. Состояние файлов,
+//которые пропали из каталога, забывается.
+func (g *stabilityGate) filter(files []*File) (res []*File) {
+	now := g.now()
+	seen := make(map[string]bool, len(files))
+
+	for _, file := range files {
+		pathName := file.AbsolutePath()
+		seen[pathName] = true
+
+		stat, err := os.Stat(pathName)
+		if err != nil {
+			delete(g.states, pathName)
+			continue
+		}
+
+		state, ok := g.states[pathName]
+		if !ok || state.size != stat.Size() || !state.modTime.Equal(stat.ModTime()) {
+			g.states[pathName] = &fileState{size: stat.Size(), modTime: stat.ModTime(), since: now}
+			continue
+		}
+
+		state.unchanged++
+		if g.isStable(state, now) {
+			res = append(res, file)
+		}
+	}
+
+	for pathName := range g.states {
+		if !seen[pathName] {
+			delete(g.states, pathName)
+		}
+	}
+
+	return
+}
+
+func (g *stabilityGate) isStable(state *fileState, now time.Time) bool {
+	if g.opts.Polls > 0 && state.unchanged >= g.opts.Polls {
+		return true
+	}
+	if g.opts.QuietPeriod > 0 && now.Sub(state.since) >= g.opts.QuietPeriod {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,145 @@
+package fs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//defaultTimeLayout формат времени в шаблоне пути, если он не указан явно ({mtime}).
+const defaultTimeLayout = "2006-01-02"
+
+//PathTemplate шаблон пути к файлу в новом расположении. Шаблон состоит из текста и подстановок в фигурных скобках:
+//{name} - имя исходного файла, {base} - имя исходного файла без расширения, {ext} - расширение исходного файла
+//(вместе с точкой), {dir} - каталог исходного файла относительно отслеживаемой папки, {mtime:layout} - время
+//модификации исходного файла, {atime:layout} - время последнего доступа к исходному файлу, {now:layout} - текущее время.
+//Время форматируется по правилам пакета time (например, {mtime:2006}/{mtime:01}/{name}),
+//если формат не указан, то используется 2006-01-02. В качестве разделителя каталогов можно использовать "/".
+type PathTemplate struct {
+	text  string
+	parts []templatePart
+}
+
+type templatePart struct {
+	literal string
+	field   string
+	layout  string
+}
+
+//ParsePathTemplate разбирает шаблон пути (см. PathTemplate).
+func ParsePathTemplate(text string) (*PathTemplate, error) {
+	if text == "" {
+		return nil, fmt.Errorf("empty path template")
+	}
+
+	t := &PathTemplate{text: text}
+	for rest := text; rest != ""; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			t.parts = append(t.parts, templatePart{literal: rest})
+			break
+		}
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{literal: rest[:start]})
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in the path template '%s'", text)
+		}
+		part, err := parsePlaceholder(rest[start+1 : start+end])
+		if err != nil {
+			return nil, fmt.Errorf("%s in the path template '%s'", err.Error(), text)
+		}
+		t.parts = append(t.parts, part)
+		rest = rest[start+end+1:]
+	}
+
+	return t, nil
+}
+
+func parsePlaceholder(placeholder string) (templatePart, error) {
+	field, layout := placeholder, ""
+	if i := strings.IndexByte(placeholder, ':'); i >= 0 {
+		field, layout = placeholder[:i], placeholder[i+1:]
+	}
+
+	switch field {
+	case "name", "base", "ext", "dir":
+		if layout != "" {
+			return templatePart{}, fmt.Errorf("placeholder '{%s}' does not accept a format", field)
+		}
+	case "mtime", "atime", "now":
+		if layout == "" {
+			layout = defaultTimeLayout
+		}
+	default:
+		return templatePart{}, fmt.Errorf("unknown placeholder '{%s}'", placeholder)
+	}
+
+	return templatePart{field: field, layout: layout}, nil
+}
+
+//Expand возвращает путь к файлу file в новом расположении.
+func (t *PathTemplate) Expand(file *File) (string, error) {
+	return t.expand(file, time.Now())
+}
+
+func (t *PathTemplate) expand(file *File, now time.Time) (string, error) {
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.field == "" {
+			b.WriteString(part.literal)
+			continue
+		}
+
+		value, err := part.value(file, now)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value)
+	}
+
+	pathName := filepath.FromSlash(b.String())
+	if strings.HasSuffix(pathName, string(filepath.Separator)) {
+		return "", fmt.Errorf("the path template '%s' gives no file name for the file '%s'", t.text, file.AbsolutePath())
+	}
+	pathName = filepath.Clean(pathName)
+	if name := filepath.Base(pathName); name == "." || name == ".." {
+		return "", fmt.Errorf("the path template '%s' gives no file name for the file '%s'", t.text, file.AbsolutePath())
+	}
+
+	return pathName, nil
+}
+
+func (p templatePart) value(file *File, now time.Time) (string, error) {
+	switch p.field {
+	case "name":
+		return file.Name(), nil
+	case "base":
+		return strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())), nil
+	case "ext":
+		return filepath.Ext(file.Name()), nil
+	case "dir":
+		return file.RelativeDir(), nil
+	case "mtime":
+		mtime, err := file.ModTime()
+		if err != nil {
+			return "", err
+		}
+		return mtime.Format(p.layout), nil
+	case "atime":
+		atime, err := file.AccessTime()
+		if err != nil {
+			return "", err
+		}
+		return atime.Format(p.layout), nil
+	default:
+		return now.Format(p.layout), nil
+	}
+}
+
+func (t *PathTemplate) String() string {
+	return t.text
+}
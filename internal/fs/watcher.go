@@ -2,25 +2,86 @@ package fs
 
 import (
 	"context"
+	"fmt"
 	"time"
 )
 
+const warningsBufferSize = 16
+
 //Watcher предназначен для отслеживания содержимого заданного каталога с заданным интервалом.
 type Watcher struct {
-	dirReader    DirReader
-	pollInterval time.Duration
-	events       chan []*File
-	errors       chan error
+	dirReader DirReader
+	backend   Backend
+	events    chan []*File
+	errors    chan error
+	stability *stabilityGate
+	changes   chan []Event
+	snapshot  Snapshot
+	queue     *eventQueue
+	retry     *retrier
+	warnings  chan error
+}
+
+//WatcherOption дополнительная настройка экземпляра Watcher.
+type WatcherOption func(w *Watcher)
+
+//WithStability включает проверку стабильности файлов: в канал событий попадают только те файлы,
+//размер и время модификации которых перестали меняться (см. StabilityOptions).
+func WithStability(opts StabilityOptions) WatcherOption {
+	return func(w *Watcher) {
+		if opts.enabled() {
+			w.stability = newStabilityGate(opts)
+		}
+	}
+}
+
+//WithBackend задаёт источник сигналов о необходимости перечитать каталог (по умолчанию - периодический опрос).
+func WithBackend(backend Backend) WatcherOption {
+	return func(w *Watcher) {
+		w.backend = backend
+	}
+}
+
+//WithChanges включает канал изменений (см. Changes), в который пишутся различия между
+//последовательными состояниями каталога. При первом чтении все файлы считаются созданными.
+func WithChanges() WatcherOption {
+	return func(w *Watcher) {
+		w.changes = make(chan []Event)
+	}
+}
+
+//WithDelivery задаёт стратегию доставки срезов файлов в канал событий, если получатель не успевает
+//их обрабатывать (по умолчанию ожидающие срезы объединяются). Размер буфера используется только
+//стратегией DeliverDropOldest. На канал изменений стратегия не распространяется.
+func WithDelivery(policy DeliveryPolicy, bufferSize int) WatcherOption {
+	return func(w *Watcher) {
+		w.queue = newEventQueue(policy, bufferSize)
+	}
+}
+
+//WithRetry включает повторные попытки чтения каталога после временных ошибок (см. RetryPolicy).
+//Сведения о неудачных попытках пишутся в канал предупреждений (см. Warnings).
+func WithRetry(policy RetryPolicy) WatcherOption {
+	return func(w *Watcher) {
+		w.retry = newRetrier(policy)
+	}
 }
 
 //NewDirWatcher возвращает настроенный экземпляр Watcher.
-func NewDirWatcher(dirReader DirReader, pollInterval time.Duration) *Watcher {
-	return &Watcher{
-		dirReader:    dirReader,
-		pollInterval: pollInterval,
-		events:       make(chan []*File),
-		errors:       make(chan error, 1),
+func NewDirWatcher(dirReader DirReader, pollInterval time.Duration, opts ...WatcherOption) *Watcher {
+	w := &Watcher{
+		dirReader: dirReader,
+		backend:   NewPollBackend(pollInterval),
+		events:    make(chan []*File),
+		errors:    make(chan error, 1),
+		queue:     newEventQueue(DeliverCoalesce, 1),
+		warnings:  make(chan error, warningsBufferSize),
 	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
 }
 
 //Watch начинает отслеживать (в бесконечном цикле) содержимое каталога.
@@ -28,38 +89,126 @@ func NewDirWatcher(dirReader DirReader, pollInterval time.Duration) *Watcher {
 //По окончании своей работы, метод закрывает каналы. Если завершение
 //работы метода было вызвано ошибкой, то её в можно прочитать в канале ошибок.
 func (w *Watcher) Watch(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Second)
-	isTickerReset := false
+	ctx, cancel := context.WithCancel(ctx)
+
+	notify := make(chan struct{}, 1)
+	backendErr := make(chan error, 1)
+	go func() {
+		backendErr <- w.backend.Run(ctx, notify)
+	}()
+
+	isBackendStopped := false
+	var retryC <-chan time.Time
 loop:
 	for {
+		var out chan<- []*File
+		next, ok := w.queue.front()
+		if ok {
+			out = w.events
+		}
+		in := notify
+		if w.queue.blocked() || retryC != nil {
+			in = nil
+		}
+
 		select {
 		case <-ctx.Done():
 			break loop
-		case <-ticker.C:
-			entries, err := w.dirReader.Read()
+		case out <- next:
+			w.queue.pop()
+		case err := <-backendErr:
+			isBackendStopped = true
 			if err != nil {
 				w.writeError(err)
-				break loop
 			}
-			if len(entries) > 0 {
-				w.writeEvent(entries)
+			break loop
+		case <-in:
+			if retryC, ok = w.readWithRetry(ctx); !ok {
+				break loop
 			}
-
-			if !isTickerReset {
-				ticker.Reset(w.pollInterval)
-				isTickerReset = true
+		case <-retryC:
+			if retryC, ok = w.readWithRetry(ctx); !ok {
+				break loop
 			}
 		}
 	}
 
-	ticker.Stop()
+	cancel()
+	if !isBackendStopped {
+		<-backendErr
+	}
 	close(w.events)
 	close(w.errors)
+	close(w.warnings)
+	if w.changes != nil {
+		close(w.changes)
+	}
+}
+
+//readWithRetry читает каталог. При временной ошибке возвращает канал, сигнализирующий о времени
+//следующей попытки; при ошибке, не позволяющей продолжить работу, записывает её в канал ошибок и возвращает false.
+func (w *Watcher) readWithRetry(ctx context.Context) (<-chan time.Time, bool) {
+	err := w.read(ctx)
+	if err == nil {
+		if w.retry != nil {
+			w.retry.reset()
+		}
+		return nil, true
+	}
+
+	if w.retry != nil {
+		if delay, ok := w.retry.next(err); ok {
+			w.writeWarning(fmt.Errorf("attempt %d to read the directory failed, next attempt in %s: %w", w.retry.attempt, delay, err))
+			return time.After(delay), true
+		}
+	}
+
+	w.writeError(err)
+	return nil, false
 }
 
-func (w *Watcher) writeEvent(entries []*File) {
+func (w *Watcher) read(ctx context.Context) error {
+	snapshotEntries, err := w.dirReader.readEntries()
+	if err != nil {
+		return err
+	}
+	if w.changes != nil {
+		w.diff(ctx, newSnapshot(snapshotEntries))
+	}
+
+	entries := make([]*File, 0, len(snapshotEntries))
+	for _, entry := range snapshotEntries {
+		entries = append(entries, entry.File)
+	}
+	if w.stability != nil {
+		entries = w.stability.filter(entries)
+	}
+	if len(entries) > 0 {
+		w.queue.push(entries)
+	}
+
+	return nil
+}
+
+func (w *Watcher) diff(ctx context.Context, snapshot Snapshot) {
+	if events := Diff(w.snapshot, snapshot); len(events) > 0 {
+		w.writeChanges(ctx, events)
+	}
+	w.snapshot = snapshot
+}
+
+//writeChanges ожидает чтения изменений, так как пропущенные изменения не будут повторены
+//при следующем чтении каталога.
+func (w *Watcher) writeChanges(ctx context.Context, events []Event) {
 	select {
-	case w.events <- entries:
+	case w.changes <- events:
+	case <-ctx.Done():
+	}
+}
+
+func (w *Watcher) writeWarning(err error) {
+	select {
+	case w.warnings <- err:
 	default:
 	}
 }
@@ -73,6 +222,23 @@ func (w *Watcher) Events() <-chan []*File {
 	return w.events
 }
 
+//Stats возвращает счётчики доставки срезов файлов в канал событий.
+func (w *Watcher) Stats() DeliveryStats {
+	return w.queue.stats()
+}
+
+//Changes возвращает канал, в который пишутся изменения содержимого отслеживаемой папки.
+//Если канал изменений не был включен опцией WithChanges, то возвращается nil.
+func (w *Watcher) Changes() <-chan []Event {
+	return w.changes
+}
+
+//Warnings возвращает канал, в который пишутся ошибки, не прерывающие работу Watcher-а
+//(например, неудачные попытки чтения каталога, которые будут повторены).
+func (w *Watcher) Warnings() <-chan error {
+	return w.warnings
+}
+
 //Errors возвращает канал, в который записывается ошибка не позволяющая экземпляру Watcher-ра
 //выполнять свою работу (после появления ошибки в этом канале, работа завершается).
 func (w *Watcher) Errors() <-chan error {
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import (
+	"errors"
+	"syscall"
+)
+
+//isCrossDeviceError возвращает true, если переименование не удалось из-за того, что исходный
+//и новый пути находятся на разных файловых системах.
+func isCrossDeviceError(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,15 @@
+package fs
+
+import (
+	"errors"
+	"syscall"
+)
+
+//errorNotSameDevice код ошибки ERROR_NOT_SAME_DEVICE
+const errorNotSameDevice syscall.Errno = 17
+
+//isCrossDeviceError возвращает true, если переименование не удалось из-за того, что исходный
+//и новый пути находятся на разных томах.
+func isCrossDeviceError(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
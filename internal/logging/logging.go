@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	flag "github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+//StderrPath значение пути к файлу журнала, при котором журнал пишется только в stderr.
+const StderrPath = "-"
+
+//Форматы записей журнала
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+//Options настройки журнала.
+type Options struct {
+	//Path путь к файлу журнала (StderrPath - журнал пишется только в stderr)
+	Path string
+	//Level минимальный уровень записей: debug, info, warn или error
+	Level string
+	//Format формат записей: json или console
+	Format string
+	//MaxSize размер файла журнала в мегабайтах, при превышении которого файл ротируется
+	MaxSize int
+	//MaxBackups количество хранимых старых файлов журнала (0 - все)
+	MaxBackups int
+	//MaxAge количество дней хранения старых файлов журнала (0 - не ограничено)
+	MaxAge int
+	//Stderr дублирует записи журнала в stderr
+	Stderr bool
+}
+
+//DefaultOptions возвращает настройки журнала по умолчанию: файл name.log рядом с исполняемым файлом,
+//записи в формате JSON с уровнем не ниже info.
+func DefaultOptions(name string) Options {
+	return Options{
+		Path:       filepath.Join(filepath.Dir(os.Args[0]), name+".log"),
+		Level:      zapcore.InfoLevel.String(),
+		Format:     FormatJSON,
+		MaxSize:    10,
+		MaxBackups: 3,
+	}
+}
+
+//AddFlags добавляет в набор флагов настройки журнала (со значениями по умолчанию из DefaultOptions).
+//Значения флагов записываются в возвращаемые настройки при разборе командной строки.
+func AddFlags(flags *flag.FlagSet, name string) *Options {
+	defaults := DefaultOptions(name)
+	o := &Options{}
+
+	flags.StringVar(&o.Path, "log-file", defaults.Path, "the log file ('-' - write the log to stderr only)")
+	flags.StringVar(&o.Level, "log-level", defaults.Level, "the minimum level of log entries: debug, info, warn or error")
+	flags.StringVar(&o.Format, "log-format", defaults.Format, "the format of log entries: json or console")
+	flags.IntVar(&o.MaxSize, "log-max-size", defaults.MaxSize, "the size of the log file in megabytes at which it is rotated")
+	flags.IntVar(&o.MaxBackups, "log-max-backups", defaults.MaxBackups, "the number of old log files to keep (0 - keep all)")
+	flags.IntVar(&o.MaxAge, "log-max-age", defaults.MaxAge, "the number of days to keep old log files (0 - do not remove old files by age)")
+	flags.BoolVar(&o.Stderr, "log-stderr", defaults.Stderr, "also write the log to stderr")
+
+	return o
+}
+
+//New возвращает журнал с указанными настройками.
+func New(o Options) (*zap.Logger, error) {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(o.Level)); err != nil {
+		return nil, fmt.Errorf("unknown log level '%s'", o.Level)
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = "time"
+	encoderConfig.EncodeTime = zapcore.RFC3339TimeEncoder
+	var encoder zapcore.Encoder
+	switch o.Format {
+	case FormatJSON:
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case FormatConsole:
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("unknown log format '%s'", o.Format)
+	}
+
+	if o.MaxSize < 0 || o.MaxBackups < 0 || o.MaxAge < 0 {
+		return nil, fmt.Errorf("the log rotation settings must not be negative")
+	}
+
+	var writers []zapcore.WriteSyncer
+	if o.Path != StderrPath {
+		if o.Path == "" {
+			return nil, fmt.Errorf("the log file is not set")
+		}
+		writers = append(writers, zapcore.AddSync(&lumberjack.Logger{
+			Filename:   o.Path,
+			MaxSize:    o.MaxSize, // megabytes
+			MaxBackups: o.MaxBackups,
+			MaxAge:     o.MaxAge, // days
+		}))
+	}
+	if o.Path == StderrPath || o.Stderr {
+		writers = append(writers, zapcore.Lock(os.Stderr))
+	}
+
+	core := zapcore.NewCore(
+		encoder,
+		zapcore.NewMultiWriteSyncer(writers...),
+		level,
+	)
+
+	return zap.New(core), nil
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddFlags(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := AddFlags(flags, "test")
+	assert.Equal(t, DefaultOptions("test"), *o)
+
+	err := flags.Parse([]string{
+		"--log-file", "-", "--log-level", "debug", "--log-format", "console",
+		"--log-max-size", "5", "--log-max-backups", "0", "--log-max-age", "7", "--log-stderr",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, Options{
+		Path:       StderrPath,
+		Level:      "debug",
+		Format:     FormatConsole,
+		MaxSize:    5,
+		MaxBackups: 0,
+		MaxAge:     7,
+		Stderr:     true,
+	}, *o)
+}
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logging")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	o := DefaultOptions("test")
+	o.Path = filepath.Join(dir, "test.log")
+	o.Level = "warn"
+	logger, err := New(o)
+	if !assert.Nil(t, err) {
+		return
+	}
+	logger.Info("skipped")
+	logger.Warn("written")
+	logger.Sync()
+
+	data, err := ioutil.ReadFile(o.Path)
+	if !assert.Nil(t, err) {
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if !assert.Len(t, lines, 1) {
+		return
+	}
+	var entry map[string]interface{}
+	if assert.Nil(t, json.Unmarshal([]byte(lines[0]), &entry)) {
+		assert.Equal(t, "written", entry["msg"])
+		assert.Equal(t, "warn", entry["level"])
+		assert.Contains(t, entry, "time")
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *Options)
+	}{
+		{"level", func(o *Options) { o.Level = "verbose" }},
+		{"format", func(o *Options) { o.Format = "xml" }},
+		{"size", func(o *Options) { o.MaxSize = -1 }},
+		{"path", func(o *Options) { o.Path = "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOptions("test")
+			tt.modify(&o)
+			_, err := New(o)
+			assert.NotNil(t, err)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package pool
+
+import (
+	"context"
+	"sync"
+)
+
+//Pool ограниченный пул обработчиков задач. Задачи с одинаковым ключом (например, путём к файлу)
+//не выполняются одновременно: пока задача с таким ключом ожидает выполнения или выполняется,
+//новые задачи с этим ключом отклоняются.
+type Pool struct {
+	tasks    chan task
+	mu       sync.Mutex
+	inFlight map[string]bool
+	wg       sync.WaitGroup
+}
+
+type task struct {
+	key string
+	fn  func()
+}
+
+//New создаёт пул и запускает указанное количество обработчиков (не меньше одного).
+func New(workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &Pool{
+		tasks:    make(chan task),
+		inFlight: make(map[string]bool),
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for t := range p.tasks {
+		t.fn()
+
+		p.mu.Lock()
+		delete(p.inFlight, t.key)
+		p.mu.Unlock()
+	}
+}
+
+//Submit передаёт задачу свободному обработчику, ожидая его освобождения. Возвращает false, если
+//задача с таким ключом уже выполняется или если ctx был отменён до того, как задачу удалось передать.
+func (p *Pool) Submit(ctx context.Context, key string, fn func()) bool {
+	p.mu.Lock()
+	if p.inFlight[key] {
+		p.mu.Unlock()
+		return false
+	}
+	p.inFlight[key] = true
+	p.mu.Unlock()
+
+	select {
+	case p.tasks <- task{key: key, fn: fn}:
+		return true
+	case <-ctx.Done():
+		p.mu.Lock()
+		delete(p.inFlight, key)
+		p.mu.Unlock()
+		return false
+	}
+}
+
+//Wait прекращает приём задач и ожидает завершения уже переданных. После вызова Wait вызывать Submit нельзя.
+func (p *Pool) Wait() {
+	close(p.tasks)
+	p.wg.Wait()
+}
@@ -0,0 +1,46 @@
+package pool
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool_Submit(t *testing.T) {
+	p := New(2)
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	assert.True(t, p.Submit(ctx, "file", func() {
+		close(started)
+		<-release
+	}))
+	<-started
+
+	//задача с тем же ключом уже выполняется
+	assert.False(t, p.Submit(ctx, "file", func() {}))
+
+	var done int32
+	assert.True(t, p.Submit(ctx, "other", func() { atomic.AddInt32(&done, 1) }))
+
+	close(release)
+	p.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&done))
+}
+
+func TestPool_SubmitCanceled(t *testing.T) {
+	p := New(1)
+	defer p.Wait()
+
+	release := make(chan struct{})
+	assert.True(t, p.Submit(context.Background(), "busy", func() { <-release }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, p.Submit(ctx, "file", func() {}))
+
+	close(release)
+}
@@ -0,0 +1,290 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vps2/futilities/internal/fs"
+
+	"gopkg.in/yaml.v3"
+)
+
+//Action действие, выполняемое над файлом, подходящим под правило.
+type Action int
+
+//Действия
+const (
+	//ActionMove перемещает файл в каталог назначения
+	ActionMove Action = iota
+	//ActionCopy копирует файл в каталог назначения, исходный файл остаётся на месте
+	ActionCopy
+	//ActionDelete удаляет файл
+	ActionDelete
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionMove:
+		return "move"
+	case ActionCopy:
+		return "copy"
+	case ActionDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+//ParseAction возвращает действие по его названию.
+func ParseAction(name string) (Action, error) {
+	for _, action := range []Action{ActionMove, ActionCopy, ActionDelete} {
+		if action.String() == name {
+			return action, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown action '%s'", name)
+}
+
+//MatchMode способ выбора правил для файла.
+type MatchMode int
+
+//Способы выбора правил
+const (
+	//MatchFirst выбирает первое подходящее правило.
+	MatchFirst MatchMode = iota
+	//MatchAll выбирает все подходящие правила по порядку. Так как после перемещения или удаления файла
+	//применять к нему другие правила уже нельзя, то выбор заканчивается на первом таком правиле.
+	MatchAll
+)
+
+func (m MatchMode) String() string {
+	switch m {
+	case MatchFirst:
+		return "first"
+	case MatchAll:
+		return "all"
+	default:
+		return "unknown"
+	}
+}
+
+//ParseMatchMode возвращает способ выбора правил по его названию.
+func ParseMatchMode(name string) (MatchMode, error) {
+	for _, mode := range []MatchMode{MatchFirst, MatchAll} {
+		if mode.String() == name {
+			return mode, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown match mode '%s'", name)
+}
+
+//Rule правило обработки файлов.
+type Rule struct {
+	//Name название правила (используется в журнале)
+	Name string
+	//Filter фильтр файлов, к которым применяется правило (nil - все файлы)
+	Filter fs.FilterFunc
+	//Action действие над файлом
+	Action Action
+	//Destination каталог назначения (для перемещения и копирования)
+	Destination string
+	//Template шаблон пути относительно каталога назначения (nil - дерево подкаталогов исходной папки сохраняется)
+	Template *fs.PathTemplate
+}
+
+//Matches проверяет, подходит ли файл под правило.
+func (r *Rule) Matches(fileInfo os.FileInfo) bool {
+	return r.Filter == nil || r.Filter(fileInfo)
+}
+
+//Target возвращает путь к файлу в каталоге назначения.
+func (r *Rule) Target(file *fs.File) (string, error) {
+	if r.Template == nil {
+		return filepath.Join(r.Destination, file.RelativePath()), nil
+	}
+
+	pathName, err := r.Template.Expand(file)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(pathName) {
+		pathName = filepath.Join(r.Destination, pathName)
+	}
+
+	return pathName, nil
+}
+
+//final проверяет, что после выполнения действия файла не останется на месте.
+func (r *Rule) final() bool {
+	return r.Action != ActionCopy
+}
+
+func (r *Rule) String() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	if r.Action == ActionDelete {
+		return r.Action.String()
+	}
+
+	return fmt.Sprintf("%s to %s", r.Action, r.Destination)
+}
+
+//Set упорядоченный набор правил.
+type Set struct {
+	Mode  MatchMode
+	Rules []*Rule
+}
+
+//Match возвращает правила, которые нужно применить к файлу (в порядке применения).
+func (s *Set) Match(fileInfo os.FileInfo) []*Rule {
+	var matched []*Rule
+	for _, rule := range s.Rules {
+		if !rule.Matches(fileInfo) {
+			continue
+		}
+
+		matched = append(matched, rule)
+		if s.Mode == MatchFirst || rule.final() {
+			break
+		}
+	}
+
+	return matched
+}
+
+//Load читает набор правил из YAML-файла (см. Parse).
+func Load(pathName string) (*Set, error) {
+	data, err := ioutil.ReadFile(pathName)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pathName, err)
+	}
+
+	return set, nil
+}
+
+//Parse разбирает набор правил в формате YAML:
+//
+//	match: first            # first или all
+//	rules:
+//	  - name: documents
+//	    ext: [pdf, docx]      # расширения
+//	    include: ["*_2021*"]  # шаблоны имён (glob или регулярное выражение с префиксом "re:")
+//	    exclude: ["~*"]
+//	    min-size: 1K
+//	    max-size: 100M
+//	    min-age: 1m
+//	    max-age: 24h
+//	    action: move          # move, copy или delete
+//	    destination: /archive/documents
+//	    template: "{mtime:2006}/{name}"
+func Parse(data []byte) (*Set, error) {
+	var config struct {
+		Match string       `yaml:"match"`
+		Rules []ruleConfig `yaml:"rules"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	set := &Set{}
+	if config.Match != "" {
+		mode, err := ParseMatchMode(config.Match)
+		if err != nil {
+			return nil, err
+		}
+		set.Mode = mode
+	}
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("no rules defined")
+	}
+	for i, rc := range config.Rules {
+		rule, err := rc.rule()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		set.Rules = append(set.Rules, rule)
+	}
+
+	return set, nil
+}
+
+type ruleConfig struct {
+	Name        string        `yaml:"name"`
+	Ext         []string      `yaml:"ext"`
+	Include     []string      `yaml:"include"`
+	Exclude     []string      `yaml:"exclude"`
+	MinSize     string        `yaml:"min-size"`
+	MaxSize     string        `yaml:"max-size"`
+	MinAge      time.Duration `yaml:"min-age"`
+	MaxAge      time.Duration `yaml:"max-age"`
+	Action      string        `yaml:"action"`
+	Destination string        `yaml:"destination"`
+	Template    string        `yaml:"template"`
+}
+
+func (c ruleConfig) rule() (*Rule, error) {
+	action, err := ParseAction(c.Action)
+	if err != nil {
+		return nil, err
+	}
+	rule := &Rule{Name: c.Name, Action: action, Destination: c.Destination}
+
+	if action == ActionDelete {
+		if c.Destination != "" || c.Template != "" {
+			return nil, fmt.Errorf("the delete action does not accept a destination")
+		}
+	} else if c.Destination == "" {
+		return nil, fmt.Errorf("the %s action requires a destination", action)
+	}
+	if c.Template != "" {
+		if rule.Template, err = fs.ParsePathTemplate(c.Template); err != nil {
+			return nil, err
+		}
+	}
+
+	patternFilter, err := fs.IncludeExclude(c.Include, c.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	filters := []fs.FilterFunc{patternFilter}
+	if len(c.Ext) > 0 {
+		filters = append(filters, fs.Extensions(c.Ext...))
+	}
+	if c.MinSize != "" {
+		size, err := fs.ParseSize(c.MinSize)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, fs.MinSize(size))
+	}
+	if c.MaxSize != "" {
+		size, err := fs.ParseSize(c.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, fs.MaxSize(size))
+	}
+	if c.MinAge > 0 {
+		filters = append(filters, fs.MinAge(c.MinAge))
+	}
+	if c.MaxAge > 0 {
+		filters = append(filters, fs.MaxAge(c.MaxAge))
+	}
+	rule.Filter = fs.And(filters...)
+
+	return rule, nil
+}
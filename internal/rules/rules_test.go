@@ -0,0 +1,120 @@
+package rules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vps2/futilities/internal/fs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	set, err := Parse([]byte(`
+match: all
+rules:
+  - name: backup
+    action: copy
+    destination: /backup
+  - name: documents
+    ext: [pdf, .DOCX]
+    max-size: 1M
+    action: move
+    destination: /archive/documents
+    template: "{mtime:2006}/{name}"
+  - name: temporary
+    include: ["*.tmp"]
+    max-age: 24h
+    action: delete
+`))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, MatchAll, set.Mode)
+	if assert.Len(t, set.Rules, 3) {
+		assert.Equal(t, ActionCopy, set.Rules[0].Action)
+		assert.Equal(t, "/archive/documents", set.Rules[1].Destination)
+		assert.NotNil(t, set.Rules[1].Template)
+		assert.Equal(t, ActionDelete, set.Rules[2].Action)
+	}
+
+	invalid := []string{
+		``,
+		`match: some`,
+		`rules: [{action: move}]`,
+		`rules: [{action: rename, destination: /tmp}]`,
+		`rules: [{action: delete, destination: /tmp}]`,
+		`rules: [{action: move, destination: /tmp, min-size: 1X}]`,
+		`rules: [{action: move, destination: /tmp, template: "{size}"}]`,
+		`rules: [{action: move, destination: /tmp, unknown: 1}]`,
+	}
+	for _, data := range invalid {
+		_, err := Parse([]byte(data))
+		assert.NotNil(t, err, data)
+	}
+}
+
+func TestSet_Match(t *testing.T) {
+	dirName, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirName)
+
+	stat := func(name string) os.FileInfo {
+		pathName := filepath.Join(dirName, name)
+		if err := ioutil.WriteFile(pathName, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fileInfo, err := os.Stat(pathName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fileInfo
+	}
+
+	copyAll := &Rule{Name: "copy", Action: ActionCopy, Destination: "/backup"}
+	movePDF := &Rule{Name: "pdf", Filter: fs.Extensions("pdf"), Action: ActionMove, Destination: "/pdf"}
+	deleteAll := &Rule{Name: "delete", Action: ActionDelete}
+	rules := []*Rule{copyAll, movePDF, deleteAll}
+
+	first := &Set{Mode: MatchFirst, Rules: rules}
+	assert.Equal(t, []*Rule{copyAll}, first.Match(stat("report.pdf")))
+
+	all := &Set{Mode: MatchAll, Rules: rules}
+	assert.Equal(t, []*Rule{copyAll, movePDF}, all.Match(stat("report.pdf")))
+	assert.Equal(t, []*Rule{copyAll, deleteAll}, all.Match(stat("image.png")))
+
+	none := &Set{Mode: MatchAll, Rules: []*Rule{movePDF}}
+	assert.Empty(t, none.Match(stat("image.png")))
+}
+
+func TestRule_Target(t *testing.T) {
+	dirName, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirName)
+
+	pathName := filepath.Join(dirName, "report.pdf")
+	if err := ioutil.WriteFile(pathName, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := fs.NewDirReader(dirName).Read()
+	if err != nil || len(files) != 1 {
+		t.Fatal(err)
+	}
+
+	rule := &Rule{Action: ActionMove, Destination: filepath.Join(dirName, "dst")}
+	target, err := rule.Target(files[0])
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dirName, "dst", "report.pdf"), target)
+
+	rule.Template, err = fs.ParsePathTemplate("{base}/{name}")
+	assert.Nil(t, err)
+	target, err = rule.Target(files[0])
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dirName, "dst", "report", "report.pdf"), target)
+}
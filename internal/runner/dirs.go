@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+func (r *Runner) checkDirFlag(name string) (err error) {
+	isFlagFound := false
+	flagValue := ""
+
+	r.flags.Visit(func(f *flag.Flag) {
+		if name == f.Name {
+			isFlagFound = true
+			flagValue = f.Value.String()
+		}
+	})
+
+	if !isFlagFound || flagValue == "" {
+		err = fmt.Errorf("'%s' flag is not set", name)
+
+	} else {
+		if err = CheckDir(flagValue); err != nil {
+			err = fmt.Errorf("the directory for the flag '%s' does not exist", name)
+		}
+	}
+
+	return err
+}
+
+//CheckDir проверяет, что папка существует.
+func CheckDir(name string) error {
+	stat, err := os.Stat(name)
+	if err != nil {
+		return fmt.Errorf("%s is not exists", name)
+	}
+
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", name)
+	}
+
+	return nil
+}
+
+//CheckDestination проверяет, что папка назначения не совпадает с отслеживаемой папкой и (при отслеживании
+//подпапок) не находится внутри неё.
+func CheckDestination(srcDir, dstDir string, recursive bool) error {
+	if filepath.Clean(srcDir) == filepath.Clean(dstDir) {
+		return fmt.Errorf("source and destination folders are the same")
+	}
+	if recursive && isSubDir(srcDir, dstDir) {
+		return fmt.Errorf("destination folder is inside the source folder")
+	}
+
+	return nil
+}
+
+func isSubDir(parent, child string) bool {
+	parent, err := filepath.Abs(parent)
+	if err != nil {
+		return false
+	}
+	child, err = filepath.Abs(child)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(parent, child)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,267 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vps2/futilities/internal/fs"
+	"github.com/vps2/futilities/internal/pool"
+
+	"go.uber.org/zap"
+)
+
+//Job настройки задания: отслеживаемая папка и способ обработки найденных в ней файлов.
+//При перезагрузке настроек перезапускаются только задания, настройки которых изменились.
+type Job struct {
+	//Name имя задания (используется в журнале)
+	Name string
+	//SrcDir отслеживаемая папка
+	SrcDir string
+	//DstDir папка назначения (может быть не задана, если утилита допускает это)
+	DstDir string
+	//PollInterval период опроса отслеживаемой папки
+	PollInterval time.Duration
+	//Spec настройки задания, зависящие от утилиты (см. Command.ParseJob). Значение должно быть
+	//сравнимым с помощью ==.
+	Spec interface{}
+}
+
+//createJobs возвращает задания, указанные флагами --watch или в файле настроек, или единственное задание,
+//указанное флагами --src-dir и --dst-dir.
+func (r *Runner) createJobs(configJobs []map[string]string) ([]Job, error) {
+	o := r.opts
+	if len(o.Watches) == 0 && len(configJobs) == 0 {
+		if err := r.checkDirFlag("src-dir"); err != nil {
+			return nil, err
+		}
+		fields := map[string]string{"src": o.SrcDir}
+		//отсутствие папки назначения проверяет утилита: она может быть необязательной
+		if o.DstDir != "" {
+			if err := r.checkDirFlag("dst-dir"); err != nil {
+				return nil, err
+			}
+			fields["dst"] = o.DstDir
+		}
+		job, err := r.parseJob(fields)
+		if err != nil {
+			return nil, err
+		}
+		return []Job{job}, nil
+	}
+
+	if o.SrcDir != "" || o.DstDir != "" {
+		return nil, fmt.Errorf("jobs can not be used together with the 'src-dir' and 'dst-dir' flags")
+	}
+
+	//задания из командной строки заменяют задания из файла настроек
+	var jobs []Job
+	if len(o.Watches) > 0 {
+		for _, s := range o.Watches {
+			fields, err := splitJobSpec(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid job '%s': %w", s, err)
+			}
+			job, err := r.parseJob(fields)
+			if err != nil {
+				return nil, fmt.Errorf("invalid job '%s': %w", s, err)
+			}
+			jobs = append(jobs, job)
+		}
+	} else {
+		for i, fields := range configJobs {
+			job, err := r.parseJob(fields)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid job %d: %w", o.ConfigFile, i+1, err)
+			}
+			jobs = append(jobs, job)
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, job := range jobs {
+		if names[job.Name] {
+			return nil, fmt.Errorf("duplicate job name '%s'", job.Name)
+		}
+		names[job.Name] = true
+	}
+
+	return jobs, nil
+}
+
+//splitJobSpec разбирает задание вида "name=docs,src=/in,dst=/out,timeout=10s".
+func splitJobSpec(s string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for _, field := range strings.Split(s, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("expected comma-separated key=value pairs")
+		}
+		fields[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return fields, nil
+}
+
+//parseJob возвращает настройки задания. Общие ключи разбираются здесь, остальные - утилитой.
+//Незаданные настройки берутся из соответствующих флагов.
+func (r *Runner) parseJob(fields map[string]string) (Job, error) {
+	job := Job{PollInterval: r.opts.PollInterval}
+	known := make(map[string]bool)
+	for _, key := range r.info.JobKeys {
+		known[key] = true
+	}
+
+	commandFields := make(map[string]string)
+	for key, value := range fields {
+		switch key {
+		case "name":
+			job.Name = value
+		case "src":
+			job.SrcDir = value
+		case "dst":
+			job.DstDir = value
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return job, fmt.Errorf("invalid timeout: %w", err)
+			}
+			job.PollInterval = timeout
+		default:
+			if !known[key] {
+				return job, fmt.Errorf("unknown key '%s'", key)
+			}
+			commandFields[key] = value
+		}
+	}
+
+	if job.SrcDir == "" {
+		return job, fmt.Errorf("the source folder is not set")
+	}
+	if err := CheckDir(job.SrcDir); err != nil {
+		return job, err
+	}
+	if job.Name == "" {
+		job.Name = job.SrcDir
+	}
+
+	spec, err := r.command.ParseJob(job, commandFields)
+	if err != nil {
+		return job, err
+	}
+	job.Spec = spec
+
+	return job, nil
+}
+
+//job задание со своим Watcher-ом. Задания одного процесса работают независимо друг от друга,
+//но используют общий пул обработчиков.
+type job struct {
+	Job
+	settings *settings
+	log      *zap.SugaredLogger
+	watcher  *fs.Watcher
+	action   Action
+	tasks    sync.WaitGroup
+}
+
+//newJob создаёт задание. startup равен true при запуске утилиты и false при перезагрузке настроек.
+func (r *Runner) newJob(log *zap.SugaredLogger, spec Job, settings *settings, startup bool) (*job, error) {
+	log = log.With("job", spec.Name)
+
+	action, err := r.command.NewAction(log, spec, startup)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := r.createWatcher(spec.SrcDir, spec.PollInterval, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &job{
+		Job:      spec,
+		settings: settings,
+		log:      log,
+		watcher:  watcher,
+		action:   action,
+	}, nil
+}
+
+//run отслеживает папку задания до отмены ctx или до ошибки Watcher-а, после чего ожидает
+//окончания обработки уже переданных файлов. Файлы обрабатываются до отмены taskCtx.
+func (j *job) run(ctx, taskCtx context.Context, workers *pool.Pool) {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer j.logStats()
+	defer j.tasks.Wait()
+	defer wg.Wait()
+	defer cancel()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		j.watcher.Watch(ctx)
+	}()
+
+	events := j.watcher.Events()
+	errs := j.watcher.Errors()
+	warnings := j.watcher.Warnings()
+
+	var stats fs.DeliveryStats
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case files := <-events:
+			if current := j.watcher.Stats(); current.Dropped != stats.Dropped || current.Coalesced != stats.Coalesced {
+				j.log.Warnf("file processing is lagging behind: %d file lists dropped, %d file lists coalesced", current.Dropped, current.Coalesced)
+				stats = current
+			}
+
+			for _, file := range files {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+
+				//файл, который ещё обрабатывается, может снова оказаться в срезе при следующем опросе,
+				//а срез, ожидавший доставки, - содержать уже обработанный файл
+				file := file
+				j.tasks.Add(1)
+				submitted := workers.Submit(ctx, file.AbsolutePath(), func() {
+					defer j.tasks.Done()
+
+					if _, err := os.Stat(file.AbsolutePath()); os.IsNotExist(err) {
+						j.log.Debugf("the file '%s' no longer exists and is skipped", file.AbsolutePath())
+						return
+					}
+					j.action.Process(taskCtx, file)
+				})
+				if !submitted {
+					j.tasks.Done()
+				}
+			}
+		case err, ok := <-warnings:
+			if !ok {
+				warnings = nil
+				continue
+			}
+			j.log.Warn(err)
+		case err := <-errs:
+			if err != nil {
+				j.log.Error(err)
+			}
+			return
+		}
+	}
+}
+
+func (j *job) logStats() {
+	stats := j.watcher.Stats()
+	j.log.Infof("file lists delivered: %d, dropped: %d, coalesced: %d", stats.Delivered, stats.Dropped, stats.Coalesced)
+}
@@ -0,0 +1,198 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vps2/futilities/internal/fs"
+	"github.com/vps2/futilities/internal/logging"
+
+	flag "github.com/spf13/pflag"
+)
+
+//Options значения общих для всех утилит флагов.
+type Options struct {
+	SrcDir        string
+	DstDir        string
+	PollInterval  time.Duration
+	Recursive     bool
+	MaxDepth      int
+	SkipHidden    bool
+	SkipSymlinks  bool
+	StablePolls   int
+	StablePeriod  time.Duration
+	WatchMode     string
+	Delivery      string
+	BufferSize    int
+	RetryWindow   time.Duration
+	RetryMaxDelay time.Duration
+	Include       []string
+	Exclude       []string
+	MinSize       string
+	MaxSize       string
+	MinAge        time.Duration
+	MaxAge        time.Duration
+	Workers       int
+	Watches       []string
+	ConfigFile    string
+	GracePeriod   time.Duration
+	Logging       *logging.Options
+}
+
+//defineFlags определяет общие флаги и флаги утилиты.
+func (r *Runner) defineFlags() {
+	flags := r.flags
+	o := &Options{}
+	r.opts = o
+
+	flags.StringVarP(&o.SrcDir, "src-dir", "s", "", "the folder where new files are tracked")
+	flags.StringVarP(&o.DstDir, "dst-dir", "d", "", r.info.DstDirUsage)
+	flags.DurationVarP(&o.PollInterval, "timeout", "t", 60*time.Second, "the timeout between polls of the source directory")
+	r.command.DefineFlags(flags)
+	flags.BoolVarP(&o.Recursive, "recursive", "r", false, "track files in subfolders and recreate their tree in the destination folder")
+	flags.IntVar(&o.MaxDepth, "max-depth", 0, "the maximum depth of tracked subfolders (0 - unlimited)")
+	flags.BoolVar(&o.SkipHidden, "skip-hidden", false, "do not track files in hidden subfolders")
+	flags.BoolVar(&o.SkipSymlinks, "skip-symlinks", false, "do not track files in subfolders that are symbolic links")
+	flags.IntVar(&o.StablePolls, "stable-polls", 0, "the number of consecutive polls during which the size and modification time of a file must not change (only with --watch-mode poll, use --stable-period otherwise)")
+	flags.DurationVar(&o.StablePeriod, "stable-period", 0, "the period during which the size and modification time of a file must not change")
+	flags.StringVar(&o.WatchMode, "watch-mode", fs.WatchModePoll, "the way to track the source directory: poll, inotify (Linux only) or auto")
+	flags.StringVar(&o.Delivery, "delivery", fs.DeliverCoalesce.String(), "what to do with new files while the previous ones are being processed: coalesce, block or drop-oldest")
+	flags.IntVar(&o.BufferSize, "buffer", 1, "the number of pending file lists kept by the drop-oldest delivery policy")
+	flags.DurationVar(&o.RetryWindow, "retry-window", 0, "how long to retry reading the source directory after transient errors (0 - do not retry)")
+	flags.DurationVar(&o.RetryMaxDelay, "retry-max-delay", time.Minute, "the maximum delay between attempts to read the source directory")
+	flags.StringArrayVar(&o.Include, "include", nil, "track only files whose names match the glob pattern (or the regular expression with the 're:' prefix); can be repeated")
+	flags.StringArrayVar(&o.Exclude, "exclude", nil, "do not track files whose names match the glob pattern (or the regular expression with the 're:' prefix); can be repeated")
+	flags.StringVar(&o.MinSize, "min-size", "", "track only files not smaller than the specified size (e.g. 512, 64K, 10MB)")
+	flags.StringVar(&o.MaxSize, "max-size", "", "track only files not larger than the specified size (e.g. 512, 64K, 10MB)")
+	flags.DurationVar(&o.MinAge, "min-age", 0, "track only files modified not later than the specified time ago")
+	flags.DurationVar(&o.MaxAge, "max-age", 0, "track only files modified not earlier than the specified time ago")
+	flags.IntVarP(&o.Workers, "jobs", "j", 1, fmt.Sprintf("the number of files %s simultaneously", r.info.Verb))
+	flags.StringArrayVar(&o.Watches, "watch", nil, fmt.Sprintf("a job: an additional pair of folders tracked by the same process instead of --src-dir and --dst-dir, e.g. name=docs,src=/in/docs,dst=/out/docs,timeout=10s (keys: %s); can be repeated", strings.Join(r.jobKeys(), ", ")))
+	flags.StringVar(&o.ConfigFile, "config", "", "the YAML file with the values of the flags: the keys are the long flag names, the 'watch' key holds the list of jobs; flags set on the command line take precedence")
+	flags.DurationVar(&o.GracePeriod, "grace-period", 5*time.Second, "how long to wait for the files being processed to finish on SIGINT or SIGTERM before their processing is interrupted (0 - interrupt immediately)")
+	o.Logging = logging.AddFlags(flags, r.info.Name)
+	r.help = flags.BoolP("help", "h", false, "show help")
+
+	flags.MarkHidden("help")
+	flags.SortFlags = false
+}
+
+//jobKeys возвращает все ключи заданий.
+func (r *Runner) jobKeys() []string {
+	return append([]string{"name", "src", "dst", "timeout"}, r.info.JobKeys...)
+}
+
+//settings общие для всех заданий настройки.
+type settings struct {
+	//fingerprint значения флагов, от которых зависят настройки
+	fingerprint    string
+	filter         fs.FilterFunc
+	deliveryPolicy fs.DeliveryPolicy
+}
+
+func (r *Runner) createSettings() (*settings, error) {
+	var err error
+	s := &settings{fingerprint: r.flagsFingerprint()}
+
+	if s.filter, err = r.createFilter(); err != nil {
+		return nil, err
+	}
+	if s.deliveryPolicy, err = fs.ParseDeliveryPolicy(r.opts.Delivery); err != nil {
+		return nil, err
+	}
+	//в остальных режимах каталог перечитывается по событиям, а не через равные промежутки времени
+	if r.opts.StablePolls > 0 && r.opts.WatchMode != fs.WatchModePoll {
+		return nil, fmt.Errorf("the 'stable-polls' flag can only be used with the '%s' watch mode, use the 'stable-period' flag instead", fs.WatchModePoll)
+	}
+
+	return s, nil
+}
+
+//flagsFingerprint возвращает значения флагов, общих для всех заданий. Флаги, задающие
+//настройки отдельных заданий, учитываются при сравнении настроек заданий (см. Job).
+func (r *Runner) flagsFingerprint() string {
+	skip := map[string]bool{
+		"src-dir": true, "dst-dir": true, "timeout": true, "watch": true, "config": true, "jobs": true, "grace-period": true, "help": true,
+	}
+	for _, name := range r.info.JobFlags {
+		skip[name] = true
+	}
+
+	var b strings.Builder
+	r.flags.VisitAll(func(f *flag.Flag) {
+		//настройки журнала не меняются без перезапуска
+		if skip[f.Name] || strings.HasPrefix(f.Name, "log-") {
+			return
+		}
+		fmt.Fprintf(&b, "%s=%s\n", f.Name, f.Value)
+	})
+
+	return b.String()
+}
+
+func (r *Runner) createFilter() (fs.FilterFunc, error) {
+	o := r.opts
+	patternFilter, err := fs.IncludeExclude(o.Include, o.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []fs.FilterFunc{fs.Regular(), fs.Not(fs.Partial()), patternFilter}
+	if o.MinSize != "" {
+		size, err := fs.ParseSize(o.MinSize)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, fs.MinSize(size))
+	}
+	if o.MaxSize != "" {
+		size, err := fs.ParseSize(o.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, fs.MaxSize(size))
+	}
+	if o.MinAge > 0 {
+		filters = append(filters, fs.MinAge(o.MinAge))
+	}
+	if o.MaxAge > 0 {
+		filters = append(filters, fs.MaxAge(o.MaxAge))
+	}
+
+	return fs.And(filters...), nil
+}
+
+func (r *Runner) createWatcher(srcDir string, pollInterval time.Duration, settings *settings) (*fs.Watcher, error) {
+	o := r.opts
+	var dirReader fs.DirReader
+	if o.Recursive {
+		dirReader = fs.NewRecursiveDirReader(srcDir, settings.filter, fs.WalkOptions{
+			MaxDepth:     o.MaxDepth,
+			SkipHidden:   o.SkipHidden,
+			SkipSymlinks: o.SkipSymlinks,
+		})
+	} else {
+		dirReader = fs.NewDirReaderWithFilter(srcDir, settings.filter)
+	}
+	backend, err := fs.NewBackend(o.WatchMode, dirReader, pollInterval)
+	if err != nil {
+		return nil, err
+	}
+	watcherOptions := []fs.WatcherOption{
+		fs.WithBackend(backend),
+		fs.WithDelivery(settings.deliveryPolicy, o.BufferSize),
+		fs.WithStability(fs.StabilityOptions{
+			Polls:       o.StablePolls,
+			QuietPeriod: o.StablePeriod,
+		}),
+	}
+	if o.RetryWindow > 0 {
+		watcherOptions = append(watcherOptions, fs.WithRetry(fs.RetryPolicy{
+			MaxDelay:   o.RetryMaxDelay,
+			MaxElapsed: o.RetryWindow,
+		}))
+	}
+
+	return fs.NewDirWatcher(dirReader, pollInterval, watcherOptions...), nil
+}
@@ -0,0 +1,316 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/vps2/futilities/internal/config"
+	"github.com/vps2/futilities/internal/fs"
+	"github.com/vps2/futilities/internal/logging"
+	"github.com/vps2/futilities/internal/pool"
+
+	flag "github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+//configCheckInterval периодичность проверки изменения файла настроек.
+const configCheckInterval = 5 * time.Second
+
+//Action действие над файлом, найденным в отслеживаемой папке.
+type Action interface {
+	//Process обрабатывает файл. Отмена ctx означает, что обработку нужно прервать.
+	Process(ctx context.Context, file *fs.File)
+}
+
+//ActionFunc функция, реализующая Action.
+type ActionFunc func(ctx context.Context, file *fs.File)
+
+//Process вызывает f(ctx, file).
+func (f ActionFunc) Process(ctx context.Context, file *fs.File) {
+	f(ctx, file)
+}
+
+//Command утилита, выполняемая Runner-ом: её флаги, настройки заданий и действие над файлами.
+type Command interface {
+	//DefineFlags определяет флаги утилиты в дополнение к общим флагам (см. Options). Вызывается
+	//при запуске и при каждой перезагрузке настроек.
+	DefineFlags(flags *flag.FlagSet)
+	//Configure проверяет значения флагов утилиты после разбора командной строки и файла настроек.
+	//opts - значения общих флагов.
+	Configure(opts *Options) error
+	//ParseJob возвращает настройки задания, зависящие от утилиты (Job.Spec). fields содержит ключи
+	//задания из Info.JobKeys, незаданные ключи берутся из соответствующих флагов.
+	ParseJob(job Job, fields map[string]string) (interface{}, error)
+	//NewAction возвращает действие задания. startup равен true при запуске утилиты
+	//и false при перезагрузке настроек.
+	NewAction(log *zap.SugaredLogger, job Job, startup bool) (Action, error)
+}
+
+//Info описание утилиты.
+type Info struct {
+	//Name имя утилиты (используется в имени файла журнала)
+	Name string
+	//Verb что утилита делает с файлами, например "moved" (используется в справке и в журнале)
+	Verb string
+	//DstDirUsage справка по флагу --dst-dir
+	DstDirUsage string
+	//JobKeys ключи заданий, которые разбирает Command.ParseJob (кроме общих ключей name, src, dst и timeout)
+	JobKeys []string
+	//JobFlags флаги, задающие значения по умолчанию для JobKeys. При перезагрузке настроек их изменение
+	//перезапускает только те задания, настройки которых изменились.
+	JobFlags []string
+}
+
+//Runner выполняет утилиту: разбирает командную строку и файл настроек, отслеживает папки заданий,
+//передаёт найденные файлы действиям заданий, перезагружает настройки и завершает работу по сигналу.
+type Runner struct {
+	info    Info
+	command Command
+	args    []string
+	flags   *flag.FlagSet
+	opts    *Options
+	help    *bool
+}
+
+//New возвращает экземпляр Runner.
+func New(info Info, command Command) *Runner {
+	return &Runner{info: info, command: command}
+}
+
+//Main выполняет утилиту с аргументами командной строки процесса. При ошибке запуска процесс завершается.
+func (r *Runner) Main() {
+	if err := r.parseFlags(os.Args[1:], flag.ExitOnError); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if len(r.args) == 0 || *r.help == true {
+		r.flags.Usage()
+		os.Exit(0)
+	}
+
+	//настройки журнала тоже могут задаваться в файле настроек, поэтому ошибка чтения
+	//файла настроек записывается в журнал уже после его создания
+	configJobs, configErr := r.loadConfig()
+	logger, err := logging.New(*r.opts.Logging)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	log := logger.Sugar()
+	defer log.Sync()
+	log.Info("The application is starting...")
+	defer log.Info("The application is stopped.")
+
+	if configErr != nil {
+		log.Fatal(configErr)
+	}
+
+	stopChan := make(chan os.Signal, 1)
+	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C) or SIGTERM (systemd, docker stop)
+	// SIGKILL or SIGQUIT (Ctrl+/) will not be caught.
+	signal.Notify(stopChan, os.Interrupt, syscall.SIGTERM)
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+
+	if err := r.run(log, configJobs, stopChan, reloadChan); err != nil {
+		log.Fatal(err)
+	}
+}
+
+//run запускает задания и ожидает сигнала завершения (stop) или остановки всех заданий. По сигналу reload
+//или при изменении файла настроек настройки перезагружаются.
+func (r *Runner) run(log *zap.SugaredLogger, configJobs []map[string]string, stop, reload <-chan os.Signal) error {
+	jobs, settings, err := r.configure(configJobs)
+	if err != nil {
+		return err
+	}
+
+	var jobList []*job
+	for _, spec := range jobs {
+		j, err := r.newJob(log, spec, settings, true)
+		if err != nil {
+			return fmt.Errorf("job '%s': %w", spec.Name, err)
+		}
+		jobList = append(jobList, j)
+	}
+
+	//обработчики общие для всех заданий
+	workers := pool.New(r.opts.Workers)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newSupervisor(ctx, log, r, workers, settings)
+	for _, j := range jobList {
+		s.start(j)
+	}
+	configChanges := r.watchConfig(ctx)
+
+	log.Info("The application is started.")
+
+	// Ждём сигнала завершения от операционной системы или остановки всех заданий,
+	// по сигналу SIGHUP или при изменении файла настроек перезагружаем настройки
+loop:
+	for {
+		select {
+		case <-stop:
+			break loop
+		case <-reload:
+			s.reload()
+		case <-configChanges:
+			s.reload()
+		case j := <-s.exited:
+			if s.remove(j) == 0 {
+				break loop
+			}
+		}
+	}
+
+	r.shutdown(log, s, cancel, stop)
+	workers.Wait()
+
+	return nil
+}
+
+//shutdown прекращает отслеживание папок и ожидает окончания обработки уже переданных файлов. По истечении
+//времени ожидания (--grace-period) или по повторному сигналу stop обработка файлов прерывается,
+//а недописанные файлы удаляются.
+func (r *Runner) shutdown(log *zap.SugaredLogger, s *supervisor, cancel context.CancelFunc, stop <-chan os.Signal) {
+	defer cancel()
+
+	s.stopAll()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		s.wait()
+	}()
+
+	gracePeriod := r.opts.GracePeriod
+	if gracePeriod <= 0 {
+		cancel()
+	} else {
+		log.Infof("waiting up to %s for the files being processed...", gracePeriod)
+	}
+	timer := time.NewTimer(gracePeriod)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.exited:
+		case <-timer.C:
+			if gracePeriod > 0 {
+				log.Warnf("the files were not processed within %s, their processing is interrupted", gracePeriod)
+			}
+			cancel()
+		case <-stop:
+			log.Warn("the processing of the files is interrupted")
+			cancel()
+		}
+	}
+}
+
+//configure проверяет значения флагов и возвращает задания и общие для них настройки.
+func (r *Runner) configure(configJobs []map[string]string) ([]Job, *settings, error) {
+	settings, err := r.createSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := r.command.Configure(r.opts); err != nil {
+		return nil, nil, err
+	}
+	jobs, err := r.createJobs(configJobs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return jobs, settings, nil
+}
+
+//parseFlags определяет флаги и разбирает аргументы командной строки.
+func (r *Runner) parseFlags(args []string, errorHandling flag.ErrorHandling) error {
+	r.args = args
+	r.flags = flag.NewFlagSet(os.Args[0], errorHandling)
+	r.flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		r.flags.PrintDefaults()
+	}
+	r.defineFlags()
+
+	return r.flags.Parse(args)
+}
+
+//loadConfig задаёт значения флагов, не указанных в командной строке, из файла настроек.
+//Возвращает задания из файла настроек.
+func (r *Runner) loadConfig() ([]map[string]string, error) {
+	if r.opts.ConfigFile == "" {
+		return nil, nil
+	}
+
+	cfg, err := config.Load(r.opts.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Apply(r.flags); err != nil {
+		return nil, fmt.Errorf("%s: %w", r.opts.ConfigFile, err)
+	}
+
+	return cfg.Jobs, nil
+}
+
+//reloadConfig заново разбирает командную строку и файл настроек.
+func (r *Runner) reloadConfig() ([]map[string]string, error) {
+	if err := r.parseFlags(r.args, flag.ContinueOnError); err != nil {
+		return nil, err
+	}
+
+	return r.loadConfig()
+}
+
+//watchConfig возвращает канал, в который пишется сигнал при изменении файла настроек.
+func (r *Runner) watchConfig(ctx context.Context) <-chan struct{} {
+	changes := make(chan struct{})
+	if r.opts.ConfigFile == "" {
+		return changes
+	}
+
+	pathName := r.opts.ConfigFile
+	version := FileVersion(pathName)
+	go func() {
+		ticker := time.NewTicker(configCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if current := FileVersion(pathName); current != version {
+					version = current
+					select {
+					case changes <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return changes
+}
+
+//FileVersion возвращает строку, которая меняется при изменении файла.
+func FileVersion(pathName string) string {
+	stat, err := os.Stat(pathName)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%d/%d", stat.ModTime().UnixNano(), stat.Size())
+}
@@ -0,0 +1,345 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/vps2/futilities/internal/fs"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const waitTimeout = 5 * time.Second
+
+//fakeCommand удаляет найденные файлы и сообщает о них в канал processed. Если задан delay,
+//то обработка файла длится delay (или до её прерывания), и файл удаляется по её окончании.
+type fakeCommand struct {
+	tag       *string
+	delay     time.Duration
+	started   chan string
+	processed chan string
+
+	mu      sync.Mutex
+	actions []string
+}
+
+func newFakeCommand() *fakeCommand {
+	return &fakeCommand{started: make(chan string, 10), processed: make(chan string, 10)}
+}
+
+func (c *fakeCommand) DefineFlags(flags *flag.FlagSet) {
+	c.tag = flags.String("tag", "", "")
+}
+
+func (c *fakeCommand) Configure(opts *Options) error {
+	if *c.tag == "invalid" {
+		return fmt.Errorf("invalid tag")
+	}
+
+	return nil
+}
+
+func (c *fakeCommand) ParseJob(job Job, fields map[string]string) (interface{}, error) {
+	if tag, ok := fields["tag"]; ok {
+		return tag, nil
+	}
+
+	return *c.tag, nil
+}
+
+func (c *fakeCommand) NewAction(log *zap.SugaredLogger, job Job, startup bool) (Action, error) {
+	c.mu.Lock()
+	c.actions = append(c.actions, fmt.Sprintf("%s:%v", job.Name, startup))
+	c.mu.Unlock()
+
+	return ActionFunc(func(ctx context.Context, file *fs.File) {
+		result := fmt.Sprintf("%s:%s:%s", job.Name, job.Spec, file.Name())
+		c.started <- result
+		if c.delay > 0 {
+			select {
+			case <-time.After(c.delay):
+			case <-ctx.Done():
+				result += ":canceled"
+			}
+		}
+		os.Remove(file.AbsolutePath())
+		c.processed <- result
+	}), nil
+}
+
+func (c *fakeCommand) createdActions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.actions...)
+}
+
+//wait ожидает обработки файла.
+func (c *fakeCommand) wait(t *testing.T) string {
+	select {
+	case s := <-c.processed:
+		return s
+	case <-time.After(waitTimeout):
+		t.Fatal("the file was not processed")
+		return ""
+	}
+}
+
+type testRunner struct {
+	*Runner
+	command *fakeCommand
+	stop    chan os.Signal
+	reload  chan os.Signal
+	done    chan error
+}
+
+func newTestRunner(t *testing.T, args ...string) *testRunner {
+	command := newFakeCommand()
+	r := New(Info{Name: "test", Verb: "processed", JobKeys: []string{"tag"}, JobFlags: []string{"tag"}}, command)
+	if err := r.parseFlags(args, flag.ContinueOnError); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testRunner{
+		Runner:  r,
+		command: command,
+		stop:    make(chan os.Signal, 1),
+		reload:  make(chan os.Signal, 1),
+		done:    make(chan error, 1),
+	}
+}
+
+func (r *testRunner) start() {
+	configJobs, err := r.loadConfig()
+	if err != nil {
+		r.done <- err
+		return
+	}
+
+	go func() {
+		r.done <- r.run(zap.NewNop().Sugar(), configJobs, r.stop, r.reload)
+	}()
+}
+
+//wait ожидает завершения Runner-а.
+func (r *testRunner) wait(t *testing.T) error {
+	select {
+	case err := <-r.done:
+		return err
+	case <-time.After(waitTimeout):
+		t.Fatal("the runner was not stopped")
+		return nil
+	}
+}
+
+func tempDirs(t *testing.T, names ...string) (string, func()) {
+	root, err := ioutil.TempDir("", "runner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := os.Mkdir(filepath.Join(root, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root, func() { os.RemoveAll(root) }
+}
+
+func writeFile(t *testing.T, pathName string) {
+	if err := ioutil.WriteFile(pathName, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunner_run(t *testing.T) {
+	root, cleanup := tempDirs(t, "src", "dst")
+	defer cleanup()
+	src := filepath.Join(root, "src")
+
+	r := newTestRunner(t, "-s", src, "-d", filepath.Join(root, "dst"), "-t", "20ms", "--tag", "flag")
+	r.start()
+
+	writeFile(t, filepath.Join(src, "a.txt"))
+	assert.Equal(t, src+":flag:a.txt", r.command.wait(t))
+	assert.Equal(t, []string{src + ":true"}, r.command.createdActions())
+
+	r.stop <- os.Interrupt
+	assert.Nil(t, r.wait(t))
+}
+
+func TestRunner_runJobs(t *testing.T) {
+	root, cleanup := tempDirs(t, "one", "two")
+	defer cleanup()
+
+	r := newTestRunner(t, "-t", "20ms",
+		"--watch", "name=one,src="+filepath.Join(root, "one")+",tag=first",
+		"--watch", "name=two,src="+filepath.Join(root, "two"))
+	r.start()
+
+	writeFile(t, filepath.Join(root, "one", "a.txt"))
+	assert.Equal(t, "one:first:a.txt", r.command.wait(t))
+	writeFile(t, filepath.Join(root, "two", "b.txt"))
+	assert.Equal(t, "two::b.txt", r.command.wait(t))
+
+	r.stop <- os.Interrupt
+	assert.Nil(t, r.wait(t))
+}
+
+func TestRunner_runProcessedFiles(t *testing.T) {
+	root, cleanup := tempDirs(t, "src")
+	defer cleanup()
+	src := filepath.Join(root, "src")
+
+	//пока обрабатывается a.txt, b.txt ожидает свободного обработчика, а срез с обоими файлами - доставки
+	r := newTestRunner(t, "-s", src, "-t", "20ms", "--delivery", "block")
+	r.command.delay = 200 * time.Millisecond
+	writeFile(t, filepath.Join(src, "a.txt"))
+	writeFile(t, filepath.Join(src, "b.txt"))
+	r.start()
+
+	assert.Equal(t, src+"::a.txt", r.command.wait(t))
+	assert.Equal(t, src+"::b.txt", r.command.wait(t))
+	select {
+	case result := <-r.command.processed:
+		t.Errorf("the file was processed again: %s", result)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	r.stop <- os.Interrupt
+	assert.Nil(t, r.wait(t))
+}
+
+func TestRunner_runInvalid(t *testing.T) {
+	root, cleanup := tempDirs(t, "src")
+	defer cleanup()
+	src := filepath.Join(root, "src")
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no source", []string{"-t", "20ms"}},
+		{"configure", []string{"-s", src, "--tag", "invalid"}},
+		{"unknown key", []string{"--watch", "src=" + src + ",color=red"}},
+		{"duplicate", []string{"--watch", "src=" + src, "--watch", "src=" + src}},
+		{"jobs and flags", []string{"-s", src, "--watch", "src=" + src}},
+		{"filter", []string{"-s", src, "--min-size", "big"}},
+		{"stable polls", []string{"-s", src, "--stable-polls", "2", "--watch-mode", "auto"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRunner(t, tt.args...)
+			r.start()
+			assert.NotNil(t, r.wait(t))
+		})
+	}
+}
+
+func TestRunner_runWatcherError(t *testing.T) {
+	root, cleanup := tempDirs(t, "src")
+	defer cleanup()
+	src := filepath.Join(root, "src")
+
+	r := newTestRunner(t, "-s", src, "-t", "20ms")
+	r.start()
+
+	writeFile(t, filepath.Join(src, "a.txt"))
+	r.command.wait(t)
+
+	//после ошибки чтения папки задание завершается, а вместе с последним заданием и Runner
+	assert.Nil(t, os.RemoveAll(src))
+	assert.Nil(t, r.wait(t))
+}
+
+func TestRunner_reload(t *testing.T) {
+	root, cleanup := tempDirs(t, "one", "two")
+	defer cleanup()
+	one := filepath.Join(root, "one")
+	two := filepath.Join(root, "two")
+	configFile := filepath.Join(root, "config.yaml")
+
+	writeConfig := func(content string) {
+		if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(fmt.Sprintf("timeout: 20ms\nwatch:\n  - name: one\n    src: %s\n", one))
+
+	r := newTestRunner(t, "--config", configFile)
+	r.start()
+
+	writeFile(t, filepath.Join(one, "a.txt"))
+	assert.Equal(t, "one::a.txt", r.command.wait(t))
+
+	//неизменное задание продолжает работать, добавленное - запускается
+	writeConfig(fmt.Sprintf("timeout: 20ms\nwatch:\n  - name: one\n    src: %s\n  - name: two\n    src: %s\n    tag: new\n", one, two))
+	r.reload <- syscall.SIGHUP
+	writeFile(t, filepath.Join(two, "b.txt"))
+	assert.Equal(t, "two:new:b.txt", r.command.wait(t))
+	assert.Equal(t, []string{"one:true", "two:false"}, r.command.createdActions())
+
+	//при ошибке в настройках задания продолжают работать со старыми настройками
+	writeConfig("timeout: 20ms\ntag: invalid\n")
+	r.reload <- syscall.SIGHUP
+	writeFile(t, filepath.Join(one, "c.txt"))
+	assert.Equal(t, "one::c.txt", r.command.wait(t))
+
+	//изменённое задание перезапускается, удалённое - останавливается
+	writeConfig(fmt.Sprintf("timeout: 20ms\nwatch:\n  - name: one\n    src: %s\n    tag: changed\n", one))
+	r.reload <- syscall.SIGHUP
+	writeFile(t, filepath.Join(one, "d.txt"))
+	assert.Equal(t, "one:changed:d.txt", r.command.wait(t))
+	assert.Equal(t, []string{"one:true", "two:false", "one:false"}, r.command.createdActions())
+
+	r.stop <- os.Interrupt
+	assert.Nil(t, r.wait(t))
+}
+
+func TestRunner_gracePeriod(t *testing.T) {
+	root, cleanup := tempDirs(t, "src")
+	defer cleanup()
+	src := filepath.Join(root, "src")
+
+	tests := []struct {
+		name        string
+		delay       time.Duration
+		gracePeriod string
+		signals     int
+		want        string
+	}{
+		{"finished", 200 * time.Millisecond, "5s", 1, src + "::a.txt"},
+		{"expired", time.Minute, "100ms", 1, src + "::a.txt:canceled"},
+		{"immediately", time.Minute, "0", 1, src + "::a.txt:canceled"},
+		{"second signal", time.Minute, "1m", 2, src + "::a.txt:canceled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRunner(t, "-s", src, "-t", "20ms", "--grace-period", tt.gracePeriod)
+			r.command.delay = tt.delay
+			r.start()
+
+			writeFile(t, filepath.Join(src, "a.txt"))
+			select {
+			case <-r.command.started:
+			case <-time.After(waitTimeout):
+				t.Fatal("the file was not processed")
+			}
+
+			for i := 0; i < tt.signals; i++ {
+				r.stop <- syscall.SIGTERM
+			}
+			assert.Equal(t, tt.want, r.command.wait(t))
+			assert.Nil(t, r.wait(t))
+		})
+	}
+}
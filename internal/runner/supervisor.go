@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vps2/futilities/internal/logging"
+	"github.com/vps2/futilities/internal/pool"
+
+	"go.uber.org/zap"
+)
+
+//supervisor запускает задания и заменяет их при перезагрузке настроек.
+type supervisor struct {
+	ctx      context.Context
+	log      *zap.SugaredLogger
+	runner   *Runner
+	workers  *pool.Pool
+	poolSize int
+	logging  logging.Options
+	settings *settings
+	running  map[string]*runningJob
+	exited   chan *runningJob
+	wg       sync.WaitGroup
+}
+
+//runningJob запущенное задание.
+type runningJob struct {
+	*job
+	cancel context.CancelFunc
+}
+
+//newSupervisor возвращает экземпляр supervisor. Отмена ctx останавливает все задания
+//и прерывает обработку файлов.
+func newSupervisor(ctx context.Context, log *zap.SugaredLogger, runner *Runner, workers *pool.Pool, settings *settings) *supervisor {
+	return &supervisor{
+		ctx:      ctx,
+		log:      log,
+		runner:   runner,
+		workers:  workers,
+		poolSize: runner.opts.Workers,
+		logging:  *runner.opts.Logging,
+		settings: settings,
+		running:  make(map[string]*runningJob),
+		exited:   make(chan *runningJob),
+	}
+}
+
+func (s *supervisor) start(j *job) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	rj := &runningJob{job: j, cancel: cancel}
+	s.running[j.Name] = rj
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+
+		j.run(ctx, s.ctx, s.workers)
+		select {
+		case s.exited <- rj:
+		case <-s.ctx.Done():
+		}
+	}()
+}
+
+//stop прекращает отслеживание папки задания. Уже переданные на обработку файлы обрабатываются до конца.
+func (s *supervisor) stop(name string) {
+	if rj, ok := s.running[name]; ok {
+		delete(s.running, name)
+		rj.cancel()
+	}
+}
+
+//stopAll прекращает отслеживание папок всех заданий.
+func (s *supervisor) stopAll() {
+	for name := range s.running {
+		s.stop(name)
+	}
+}
+
+//remove забывает о завершившемся задании. Возвращает количество оставшихся заданий.
+func (s *supervisor) remove(rj *runningJob) int {
+	if s.running[rj.Name] == rj {
+		delete(s.running, rj.Name)
+	}
+
+	return len(s.running)
+}
+
+//reload перечитывает настройки и перезапускает задания, настройки которых изменились. Если новые
+//настройки содержат ошибку, то задания продолжают работать со старыми настройками.
+func (s *supervisor) reload() {
+	s.log.Info("reloading the configuration...")
+
+	r := s.runner
+	configJobs, err := r.reloadConfig()
+	if err != nil {
+		s.log.Errorf("the configuration was not reloaded: %s", err)
+		return
+	}
+	specs, settings, err := r.configure(configJobs)
+	if err != nil {
+		s.log.Errorf("the configuration was not reloaded: %s", err)
+		return
+	}
+	if settings.fingerprint == s.settings.fingerprint {
+		settings = s.settings
+	}
+	if r.opts.Workers != s.poolSize {
+		s.log.Warnf("the number of files %s simultaneously can not be changed without a restart", r.info.Verb)
+	}
+	if *r.opts.Logging != s.logging {
+		s.log.Warnf("the logging settings can not be changed without a restart")
+	}
+
+	var changed []*job
+	names := make(map[string]bool)
+	for _, spec := range specs {
+		names[spec.Name] = true
+		if rj, ok := s.running[spec.Name]; ok && rj.Job == spec && rj.settings == settings {
+			continue
+		}
+
+		j, err := r.newJob(s.log, spec, settings, false)
+		if err != nil {
+			s.log.Errorf("the configuration was not reloaded: job '%s': %s", spec.Name, err)
+			return
+		}
+		changed = append(changed, j)
+	}
+
+	s.settings = settings
+	for name := range s.running {
+		if !names[name] {
+			s.running[name].log.Info("the job was removed from the configuration and is stopped")
+			s.stop(name)
+		}
+	}
+	for _, j := range changed {
+		if _, ok := s.running[j.Name]; ok {
+			j.log.Info("the job settings were changed, the job is restarted")
+			s.stop(j.Name)
+		} else {
+			j.log.Info("the job was added to the configuration and is started")
+		}
+		s.start(j)
+	}
+
+	s.log.Infof("the configuration was reloaded, changed jobs: %d", len(changed))
+}
+
+//wait ожидает завершения всех заданий.
+func (s *supervisor) wait() {
+	s.wg.Wait()
+}